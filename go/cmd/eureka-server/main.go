@@ -0,0 +1,23 @@
+package main
+
+import (
+	"MicroserviceTemplate/pkg/eureka/eurekatest"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// main - Runs the fake Eureka server for local development, its registry is lost when it stops.
+//
+//	go run ./cmd/eureka-server -port 8761
+func main() {
+
+	port := flag.Int("port", 8761, "port the Eureka server listens on")
+	flag.Parse()
+
+	log.Printf("fake Eureka server listening on http://localhost:%d/eureka", *port)
+
+	log.Fatalln(http.ListenAndServe(":"+strconv.Itoa(*port), eurekatest.NewRegistry()))
+
+}
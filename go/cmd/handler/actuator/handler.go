@@ -0,0 +1,163 @@
+package actuator
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/registry"
+	"MicroserviceTemplate/pkg/web"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ? ==================== Interfaces ====================
+
+type IHandler interface {
+	Refresh() gin.HandlerFunc
+	ServiceRegistry() gin.HandlerFunc
+	SetServiceRegistryStatus() gin.HandlerFunc
+}
+
+// ? ==================== Structs ==================== ?
+
+type Handler struct {
+	refresher       config.IRefresher
+	serviceRegistry registry.IServiceRegistry
+	registryType    string
+}
+
+// * =========== *
+
+// StatusRequest is the status an operator sets on the instance in the service registry
+type StatusRequest struct {
+	Status string `json:"status" binding:"required" example:"OUT_OF_SERVICE"`
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewHandler returns a new actuator handler
+func NewHandler(refresher config.IRefresher, serviceRegistry registry.IServiceRegistry, registryConfig config.RegistryConfig) IHandler {
+	return &Handler{refresher, serviceRegistry, registryConfig.Type}
+}
+
+// ? ===================== Methods ==================== ?
+
+// Refresh 		Reloads the configuration from the config server
+// @Summary 	Refresh configuration
+// @Tags 		Actuator
+// @Description Fetches the configuration from the config server again and returns the keys that changed, like Spring Cloud does
+// @Description Requires the security.admin-role realm role
+// @Produce  	json
+// @Security 	BearerAuth
+// @Success 	200 {array} string
+// @Failure 	401 {object} web.ErrorResponse
+// @Failure 	403 {object} web.ErrorResponse
+// @Failure 	503 {object} web.ProblemDetails
+// @Router 		/actuator/refresh [post]
+func (handler *Handler) Refresh() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		changes, err := handler.refresher.Refresh()
+		if err != nil {
+			_ = c.Error(web.NewError(http.StatusServiceUnavailable, "config_unavailable", "couldn't refresh the configuration: "+err.Error(), err))
+			return
+		}
+
+		keys := make([]string, 0, len(changes))
+		for _, change := range changes {
+			keys = append(keys, change.Key)
+		}
+
+		web.SuccessResponseBody(c, http.StatusOK, keys)
+
+	}
+}
+
+// * =========== *
+
+// ServiceRegistry 	Returns the status of the instance in the service registry
+// @Summary 	Service registry status
+// @Tags 		Actuator
+// @Description Returns the status of the instance in the service registry and the status set by an operator, UNKNOWN when there is none
+// @Description Only the Eureka registry supports it
+// @Produce  	json
+// @Security 	BearerAuth
+// @Success 	200 {object} registry.InstanceStatus
+// @Failure 	401 {object} web.ErrorResponse
+// @Failure 	501 {object} web.ProblemDetails
+// @Router 		/actuator/service-registry [get]
+func (handler *Handler) ServiceRegistry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		overrider, err := handler.statusOverrider()
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		web.SuccessResponseBody(c, http.StatusOK, overrider.InstanceStatus())
+
+	}
+}
+
+// * =========== *
+
+// SetServiceRegistryStatus 	Overrides the status of the instance in the service registry
+// @Summary 	Override service registry status
+// @Tags 		Actuator
+// @Description OUT_OF_SERVICE takes the instance out of the load balancers before a maintenance, UP gives it back to its health
+// @Description Requires the security.admin-role realm role, only the Eureka registry supports it
+// @Accept  	json
+// @Param 		status body StatusRequest true "Status of the instance"
+// @Produce  	json
+// @Security 	BearerAuth
+// @Success 	200 {object} registry.InstanceStatus
+// @Failure 	400 {object} web.ProblemDetails
+// @Failure 	401 {object} web.ErrorResponse
+// @Failure 	403 {object} web.ErrorResponse
+// @Failure 	501 {object} web.ProblemDetails
+// @Failure 	503 {object} web.ProblemDetails
+// @Router 		/actuator/service-registry [post]
+func (handler *Handler) SetServiceRegistryStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		overrider, err := handler.statusOverrider()
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		var request StatusRequest
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			_ = c.Error(web.NewError(http.StatusBadRequest, "invalid_status", err.Error(), err))
+			return
+		}
+
+		err = overrider.OverrideStatus(request.Status)
+
+		switch {
+		case errors.Is(err, registry.ErrInvalidStatus):
+			_ = c.Error(web.NewError(http.StatusBadRequest, "invalid_status", err.Error(), err))
+		case err != nil:
+			_ = c.Error(web.NewError(http.StatusServiceUnavailable, "registry_unavailable", "couldn't override the status of the instance: "+err.Error(), err))
+		default:
+			web.SuccessResponseBody(c, http.StatusOK, overrider.InstanceStatus())
+		}
+
+	}
+}
+
+// * =========== *
+
+// statusOverrider returns the service registry when it lets an operator override the status of the instance, and a
+// 501 error naming the registry otherwise
+func (handler *Handler) statusOverrider() (registry.IStatusOverrider, error) {
+
+	overrider, ok := handler.serviceRegistry.(registry.IStatusOverrider)
+	if !ok {
+		return nil, web.NewError(http.StatusNotImplemented, "registry_unsupported", "the "+handler.registryType+" service registry does not support the status of the instance, only eureka does", nil)
+	}
+
+	return overrider, nil
+
+}
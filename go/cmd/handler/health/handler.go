@@ -0,0 +1,116 @@
+package health
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/health"
+	"MicroserviceTemplate/pkg/middleware"
+	"MicroserviceTemplate/pkg/web"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ? ==================== Interfaces ====================
+
+type IHandler interface {
+	Health() gin.HandlerFunc
+	Liveness() gin.HandlerFunc
+	Readiness() gin.HandlerFunc
+}
+
+// ? ==================== Structs ==================== ?
+
+type Handler struct {
+	health      health.IHealth
+	showDetails string
+	keycloak    config.KeycloakConfig
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewHandler returns a new health handler
+func NewHandler(health health.IHealth, management config.ManagementConfig, keycloak config.KeycloakConfig) IHandler {
+	return &Handler{health, management.Health.ShowDetails, keycloak}
+}
+
+// ? ===================== Methods ==================== ?
+
+// Health 		Returns the aggregate health of the application
+// @Summary 	Application health
+// @Tags 		Health
+// @Description Aggregate health of the application and its dependencies in the Spring Boot Actuator format.
+// @Description The details of the components are only shown to authorized callers unless management.health.show-details says otherwise.
+// @Produce  	json
+// @Success 	200 {object} health.Component
+// @Failure 	503 {object} health.Component
+// @Router 		/health [get]
+func (handler *Handler) Health() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler.writeComponent(c, handler.health.Health(c.Request.Context()))
+	}
+}
+
+// * =========== *
+
+// Liveness 	Returns whether the application is running
+// @Summary 	Liveness probe
+// @Tags 		Health
+// @Description Liveness state of the application, it does not depend on external services
+// @Produce  	json
+// @Success 	200 {object} health.Component
+// @Router 		/health/liveness [get]
+func (handler *Handler) Liveness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler.writeComponent(c, handler.health.Liveness(c.Request.Context()))
+	}
+}
+
+// * =========== *
+
+// Readiness 	Returns whether the application can serve traffic
+// @Summary 	Readiness probe
+// @Tags 		Health
+// @Description Readiness state of the application, it is down while any of its dependencies is down, except the ones in management.health.readiness.exclude
+// @Produce  	json
+// @Success 	200 {object} health.Component
+// @Failure 	503 {object} health.Component
+// @Router 		/health/readiness [get]
+func (handler *Handler) Readiness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler.writeComponent(c, handler.health.Readiness(c.Request.Context()))
+	}
+}
+
+// * =========== *
+
+// writeComponent responds with the component, using 503 when it is not up like Spring Boot Actuator does. The details
+// are hidden from the callers that may not see them, since they name the internal services and their errors.
+func (handler *Handler) writeComponent(c *gin.Context, component health.Component) {
+
+	status := http.StatusOK
+	if component.Status == health.StatusDown || component.Status == health.StatusOutOfService {
+		status = http.StatusServiceUnavailable
+	}
+
+	if !handler.canShowDetails(c) {
+		component = health.WithoutDetails(component)
+	}
+
+	web.SuccessResponseBody(c, status, component)
+
+}
+
+// * =========== *
+
+// canShowDetails reports whether the caller may see the details of the components
+func (handler *Handler) canShowDetails(c *gin.Context) bool {
+
+	switch handler.showDetails {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return middleware.IsAuthorized(handler.keycloak, c)
+	}
+
+}
@@ -4,8 +4,12 @@ import (
 	"MicroserviceTemplate/internal/domain"
 	"MicroserviceTemplate/internal/product"
 	"MicroserviceTemplate/pkg/web"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 // ? ==================== Interfaces ====================
@@ -34,28 +38,81 @@ func NewHandler(service product.IService) IHandler {
 
 // ? ===================== Methods ==================== ?
 
-// GetAll 		Returns all products
+// GetAll 		Returns a page of products
 // @Summary 	Get all products
 // @Tags 		Products
-// @Description Get all products
+// @Description Get a page of products filtered and sorted by the query parameters.
+// @Description When the after parameter is sent the products that follow the continuation token are returned instead of a numbered page, an empty token starts from the beginning.
+// @Description The numbered pages stop at page 1000, the next link of that page follows its continuation token.
+// @Param 		page query int false "Page number (zero-based, max 1000)"
+// @Param 		size query int false "Page size (max 100)"
+// @Param 		sort query string false "Sort field and direction, e.g. price,desc (id, name, price or quantity)"
+// @Param 		name query string false "Name prefix"
+// @Param 		minPrice query number false "Minimum price"
+// @Param 		maxPrice query number false "Maximum price"
+// @Param 		minQuantity query int false "Minimum quantity"
+// @Param 		maxQuantity query int false "Maximum quantity"
+// @Param 		after query string false "Continuation token returned as nextToken by the previous page"
 // @Produce  	json
-// @Success 	200 {object} domain.Products
+// @Success 	200 {object} domain.ProductPage
+// @Success 	200 {object} domain.ProductCursorPage
+// @Failure 	400 {object} web.ProblemDetails
 // @Failure 	401 {object} web.ErrorResponse
+// @Failure 	503 {object} web.ProblemDetails
 // @Security    BearerAuth
 // @Router 		/products [get]
 func (handler *Handler) GetAll() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		products, err := handler.service.GetAll()
+
+		query, err := parseProductQuery(c)
+		if err != nil {
+			_ = c.Error(product.NewError(product.KindValidation, err.Error(), err))
+			return
+		}
+
+		if after, keyset := c.GetQuery("after"); keyset {
+			handler.getAllAfter(c, query, after)
+			return
+		}
+
+		page, err := handler.service.GetAll(c.Request.Context(), query)
 		if err != nil {
-			web.ErrorResponseBody(c, http.StatusInternalServerError, "get_all_error", err.Error())
+			_ = c.Error(err)
 			return
 		}
-		web.SuccessResponseBody(c, http.StatusOK, products)
+
+		page.Links = buildPageLinks(c.Request.URL, page)
+
+		web.SuccessResponseBody(c, http.StatusOK, page)
+
 	}
 }
 
 // * =========== *
 
+// getAllAfter writes the products that follow the continuation token
+func (handler *Handler) getAllAfter(c *gin.Context, query *domain.ProductQuery, after string) {
+
+	page, err := handler.service.GetAllAfter(c.Request.Context(), query, after)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	page.Links = domain.PageLinks{Self: c.Request.URL.RequestURI()}
+
+	if page.NextToken != "" {
+		next := c.Request.URL.Query()
+		next.Set("after", page.NextToken)
+		page.Links.Next = c.Request.URL.Path + "?" + next.Encode()
+	}
+
+	web.SuccessResponseBody(c, http.StatusOK, page)
+
+}
+
+// * =========== *
+
 // GetByID 		Returns a product by its ID
 // @Summary 	Get product by ID
 // @Tags 		Products
@@ -63,8 +120,10 @@ func (handler *Handler) GetAll() gin.HandlerFunc {
 // @Param 		id path string true "Product ID"
 // @Produce 	json
 // @Success 	200 {object} domain.Product
-// @Failure 	404 {object} web.ErrorResponse
+// @Header 		200 {string} ETag "Version of the product"
+// @Failure 	404 {object} web.ProblemDetails
 // @Failure 	401 {object} web.ErrorResponse
+// @Failure 	503 {object} web.ProblemDetails
 // @Security 	BearerAuth
 // @Router 		/products/{id} [get]
 func (handler *Handler) GetByID() gin.HandlerFunc {
@@ -72,12 +131,13 @@ func (handler *Handler) GetByID() gin.HandlerFunc {
 
 		id := c.Param("id")
 
-		productById, err := handler.service.GetByID(id)
+		productById, err := handler.service.GetByID(c.Request.Context(), id)
 		if err != nil {
-			web.ErrorResponseBody(c, http.StatusNotFound, "not_found", "Product not found")
+			_ = c.Error(err)
 			return
 		}
 
+		c.Header("ETag", etag(productById))
 		web.SuccessResponseBody(c, http.StatusOK, productById)
 
 	}
@@ -93,8 +153,10 @@ func (handler *Handler) GetByID() gin.HandlerFunc {
 // @Param 		product body domain.Product true "Product to save"
 // @Produce 	json
 // @Success 	201 {object} domain.Product
-// @Failure 	400 {object} web.ErrorResponse
+// @Header 		201 {string} ETag "Version of the product"
+// @Failure 	400 {object} web.ProblemDetails
 // @Failure 	401 {object} web.ErrorResponse
+// @Failure 	503 {object} web.ProblemDetails
 // @Security 	BearerAuth
 // @Router 		/products [post]
 func (handler *Handler) Save() gin.HandlerFunc {
@@ -103,16 +165,17 @@ func (handler *Handler) Save() gin.HandlerFunc {
 		var productToSave domain.Product
 
 		if err := c.ShouldBindJSON(&productToSave); err != nil {
-			web.ErrorResponseBody(c, http.StatusBadRequest, "invalid_json", err.Error())
+			_ = c.Error(product.NewError(product.KindValidation, err.Error(), err))
 			return
 		}
 
-		productSaved, err := handler.service.Save(&productToSave)
+		productSaved, err := handler.service.Save(c.Request.Context(), &productToSave)
 		if err != nil {
-			web.ErrorResponseBody(c, http.StatusBadRequest, "save_error", err.Error())
+			_ = c.Error(err)
 			return
 		}
 
+		c.Header("ETag", etag(&productSaved))
 		web.SuccessResponseBody(c, http.StatusCreated, productSaved)
 
 	}
@@ -123,14 +186,19 @@ func (handler *Handler) Save() gin.HandlerFunc {
 // Update 		updates a product
 // @Summary 	Update a product
 // @Tags 		Products
-// @Description Update a product
+// @Description Update a product, when If-Match is sent the product is only updated if it still has that version
 // @Param 		id path string true "Product ID"
+// @Param 		If-Match header string false "ETag of the version being updated"
 // @Accept  	json
 // @Param 		product body domain.Product true "Product to update"
 // @Produce  	json
 // @Success 	200 {object} domain.Product
-// @Failure 	400 {object} web.ErrorResponse
+// @Header 		200 {string} ETag "Version of the product"
+// @Failure 	400 {object} web.ProblemDetails
 // @Failure 	401 {object} web.ErrorResponse
+// @Failure 	404 {object} web.ProblemDetails
+// @Failure 	412 {object} web.ProblemDetails
+// @Failure 	503 {object} web.ProblemDetails
 // @Security 	BearerAuth
 // @Router 		/products/{id} [put]
 func (handler *Handler) Update() gin.HandlerFunc {
@@ -141,19 +209,26 @@ func (handler *Handler) Update() gin.HandlerFunc {
 		var productToUpdate domain.Product
 
 		if err := c.ShouldBindJSON(&productToUpdate); err != nil {
-			web.ErrorResponseBody(c, http.StatusBadRequest, "invalid_json", err.Error())
+			_ = c.Error(product.NewError(product.KindValidation, err.Error(), err))
+			return
+		}
+
+		expectedVersion, err := handler.expectedVersion(c, id)
+		if err != nil {
+			_ = c.Error(err)
 			return
 		}
 
 		productToUpdate.ID = id
 
-		err := handler.service.Update(&productToUpdate)
+		productUpdated, err := handler.service.Update(c.Request.Context(), &productToUpdate, expectedVersion)
 		if err != nil {
-			web.ErrorResponseBody(c, http.StatusBadRequest, "update_error", err.Error())
+			_ = c.Error(err)
 			return
 		}
 
-		web.SuccessResponseBody(c, http.StatusOK, "Product updated")
+		c.Header("ETag", etag(productUpdated))
+		web.SuccessResponseBody(c, http.StatusOK, productUpdated)
 
 	}
 
@@ -161,17 +236,25 @@ func (handler *Handler) Update() gin.HandlerFunc {
 
 // * =========== *
 
-// PatchUpdate 	Partially update a product (only the fields that are sent)
-// @Summary 	Patch update a product (only the fields that are sent)
+// PatchUpdate 	Partially update a product with a JSON Merge Patch or a JSON Patch document
+// @Summary 	Patch update a product
 // @Tags 		Products
-// @Description Patch update a product (only the fields that are sent)
-// @Accept  	json
-// @Param 		product body domain.Product true "Product to patch"
+// @Description Patch update a product with a JSON Merge Patch (RFC 7396, also used for application/json) or a JSON Patch (RFC 6902) document, including test operations.
+// @Description When If-Match is sent the product is only updated if it still has that version
+// @Accept  	json,application/merge-patch+json,application/json-patch+json
+// @Param 		patch body object true "Merge patch object or array of JSON Patch operations"
 // @Param 		id path string true "Product ID"
+// @Param 		If-Match header string false "ETag of the version being updated"
 // @Produce  	json
 // @Success 	200 {object} domain.Product
-// @Failure 	400 {object} web.ErrorResponse
+// @Header 		200 {string} ETag "Version of the product"
+// @Failure 	400 {object} web.ProblemDetails
 // @Failure 	401 {object} web.ErrorResponse
+// @Failure 	404 {object} web.ProblemDetails
+// @Failure 	409 {object} web.ProblemDetails
+// @Failure 	412 {object} web.ProblemDetails
+// @Failure 	415 {object} web.ProblemDetails
+// @Failure 	503 {object} web.ProblemDetails
 // @Security 	BearerAuth
 // @Router 		/products/{id} [patch]
 func (handler *Handler) PatchUpdate() gin.HandlerFunc {
@@ -179,22 +262,31 @@ func (handler *Handler) PatchUpdate() gin.HandlerFunc {
 
 		id := c.Param("id")
 
-		var productToUpdate domain.Product
-
-		if err := c.ShouldBindJSON(&productToUpdate); err != nil {
-			web.ErrorResponseBody(c, http.StatusBadRequest, "invalid_json", err.Error())
+		patch, err := c.GetRawData()
+		if err != nil {
+			_ = c.Error(product.NewError(product.KindValidation, err.Error(), err))
 			return
 		}
 
-		productToUpdate.ID = id
+		patchType := c.ContentType()
+		if patchType == gin.MIMEJSON {
+			patchType = product.MergePatch
+		}
 
-		err := handler.service.PatchUpdate(&productToUpdate)
+		expectedVersion, err := handler.expectedVersion(c, id)
 		if err != nil {
-			web.ErrorResponseBody(c, http.StatusBadRequest, "update_error", err.Error())
+			_ = c.Error(err)
 			return
 		}
 
-		web.SuccessResponseBody(c, http.StatusOK, "Product updated")
+		productUpdated, err := handler.service.PatchUpdate(c.Request.Context(), id, patch, patchType, expectedVersion)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		c.Header("ETag", etag(productUpdated))
+		web.SuccessResponseBody(c, http.StatusOK, productUpdated)
 
 	}
 
@@ -205,12 +297,16 @@ func (handler *Handler) PatchUpdate() gin.HandlerFunc {
 // Delete 		deletes a product
 // @Summary 	Delete a product
 // @Tags 		Products
-// @Description Delete a product
+// @Description Delete a product, when If-Match is sent the product is only deleted if it still has that version
 // @Param 		id path string true "Product ID"
+// @Param 		If-Match header string false "ETag of the version being deleted"
 // @Produce 	json
-// @Success 	204 {object} domain.Product
-// @Failure 	400 {object} web.ErrorResponse
+// @Success 	204 "Product deleted"
+// @Failure 	400 {object} web.ProblemDetails
 // @Failure 	401 {object} web.ErrorResponse
+// @Failure 	404 {object} web.ProblemDetails
+// @Failure 	412 {object} web.ProblemDetails
+// @Failure 	503 {object} web.ProblemDetails
 // @Security 	BearerAuth
 // @Router 		/products/{id} [delete]
 func (handler *Handler) Delete() gin.HandlerFunc {
@@ -218,13 +314,263 @@ func (handler *Handler) Delete() gin.HandlerFunc {
 
 		id := c.Param("id")
 
-		err := handler.service.Delete(id)
+		expectedVersion, err := handler.expectedVersion(c, id)
 		if err != nil {
-			web.ErrorResponseBody(c, http.StatusBadRequest, "delete_error", err.Error())
+			_ = c.Error(err)
 			return
 		}
 
-		web.SuccessResponseBody(c, http.StatusOK, "Product deleted")
+		err = handler.service.Delete(c.Request.Context(), id, expectedVersion)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+
+	}
+}
+
+// * =========== *
+
+// expectedVersion returns the version the write expects to find, AnyVersion when the If-Match header accepts any. When
+// the header lists several versions the one the product has now is expected, so the write still fails if it changes.
+func (handler *Handler) expectedVersion(c *gin.Context, id string) (int64, error) {
+
+	versions, ok := parseIfMatch(c)
+
+	switch {
+	case !ok:
+		return 0, product.ErrVersionMismatch
+	case versions == nil:
+		return product.AnyVersion, nil
+	case len(versions) == 1:
+		return versions[0], nil
+	}
+
+	current, err := handler.service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, version := range versions {
+		if version == current.Version {
+			return version, nil
+		}
+	}
+
+	return 0, product.ErrVersionMismatch
+
+}
+
+// ? ===================== Functions ==================== ?
+
+// parseProductQuery builds a product query from the request query parameters
+func parseProductQuery(c *gin.Context) (*domain.ProductQuery, error) {
+
+	query := domain.NewProductQuery()
+	var err error
+
+	if raw := c.Query("page"); raw != "" {
+		if query.Page, err = strconv.Atoi(raw); err != nil || query.Page < 0 || query.Page > domain.MaxPage {
+			return nil, fmt.Errorf("page must be an integer between 0 and %d, use the after parameter to read further", domain.MaxPage)
+		}
+	}
+
+	if raw := c.Query("size"); raw != "" {
+		if query.Size, err = strconv.Atoi(raw); err != nil || query.Size <= 0 || query.Size > domain.MaxPageSize {
+			return nil, fmt.Errorf("size must be an integer between 1 and %d", domain.MaxPageSize)
+		}
+	}
+
+	if raw := c.Query("sort"); raw != "" {
+
+		field, order, _ := strings.Cut(raw, ",")
+
+		if _, ok := domain.ProductSortFields[field]; !ok {
+			return nil, fmt.Errorf("products cannot be sorted by %q", field)
+		}
+		query.SortField = field
+
+		if order != "" {
+			order = strings.ToLower(order)
+			if order != domain.SortAscending && order != domain.SortDescending {
+				return nil, fmt.Errorf("sort direction must be %s or %s", domain.SortAscending, domain.SortDescending)
+			}
+			query.SortOrder = order
+		}
+
+	}
+
+	query.NamePrefix = c.Query("name")
+
+	if query.MinPrice, err = parseFloatParam(c, "minPrice"); err != nil {
+		return nil, err
+	}
 
+	if query.MaxPrice, err = parseFloatParam(c, "maxPrice"); err != nil {
+		return nil, err
 	}
+
+	if query.MinQuantity, err = parseIntParam(c, "minQuantity"); err != nil {
+		return nil, err
+	}
+
+	if query.MaxQuantity, err = parseIntParam(c, "maxQuantity"); err != nil {
+		return nil, err
+	}
+
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return nil, fmt.Errorf("minPrice cannot be greater than maxPrice")
+	}
+
+	if query.MinQuantity != nil && query.MaxQuantity != nil && *query.MinQuantity > *query.MaxQuantity {
+		return nil, fmt.Errorf("minQuantity cannot be greater than maxQuantity")
+	}
+
+	return query, nil
+
+}
+
+// * =========== *
+
+// parseFloatParam returns the value of an optional decimal query parameter
+func parseFloatParam(c *gin.Context, name string) (*float64, error) {
+
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", name)
+	}
+
+	return &value, nil
+
+}
+
+// * =========== *
+
+// parseIntParam returns the value of an optional integer query parameter
+func parseIntParam(c *gin.Context, name string) (*int, error) {
+
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+
+	return &value, nil
+
+}
+
+// * =========== *
+
+// etag returns the entity tag of the product's current version
+func etag(product *domain.Product) string {
+	return strconv.Quote(strconv.FormatInt(product.Version, 10))
+}
+
+// * =========== *
+
+// parseIfMatch returns the versions accepted by the If-Match header, nil when it accepts any because it is missing or
+// is *. If-Match uses the strong comparison of RFC 9110, so the weak tags never match and it is not ok when the header
+// lists no strong tag of a version.
+func parseIfMatch(c *gin.Context) ([]int64, bool) {
+
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	var versions []int64
+
+	for rest := header; ; {
+
+		rest = strings.TrimLeft(rest, " \t,")
+		if rest == "" {
+			break
+		}
+
+		weak := strings.HasPrefix(rest, "W/")
+		rest = strings.TrimPrefix(rest, "W/")
+
+		// The tags are quoted and may contain commas, so the list is not split by them
+		if !strings.HasPrefix(rest, `"`) {
+			return nil, false
+		}
+
+		end := strings.IndexByte(rest[1:], '"')
+		if end < 0 {
+			return nil, false
+		}
+
+		tag := rest[1 : end+1]
+		rest = rest[end+2:]
+
+		if weak {
+			continue
+		}
+
+		if version, err := strconv.ParseInt(tag, 10, 64); err == nil && version >= 0 {
+			versions = append(versions, version)
+		}
+
+	}
+
+	return versions, len(versions) > 0
+
+}
+
+// * =========== *
+
+// buildPageLinks returns the links to the current, first, previous, next and last pages of the listing
+func buildPageLinks(requestUrl *url.URL, page *domain.ProductPage) domain.PageLinks {
+
+	pageUrl := func(number int) string {
+		query := requestUrl.Query()
+		query.Set("page", strconv.Itoa(number))
+		query.Set("size", strconv.Itoa(page.Size))
+		return requestUrl.Path + "?" + query.Encode()
+	}
+
+	links := domain.PageLinks{Self: pageUrl(page.Page)}
+
+	if page.TotalPages == 0 {
+		return links
+	}
+
+	// The numbered pages stop at domain.MaxPage, the link past it follows the continuation token instead
+	last := page.TotalPages - 1
+	if last > domain.MaxPage {
+		last = domain.MaxPage
+	}
+
+	links.First = pageUrl(0)
+	links.Last = pageUrl(last)
+
+	if page.Page > 0 {
+		links.Prev = pageUrl(page.Page - 1)
+	}
+
+	switch {
+	case page.Page >= page.TotalPages-1:
+	case page.Page < domain.MaxPage:
+		links.Next = pageUrl(page.Page + 1)
+	case page.NextToken != "":
+		query := requestUrl.Query()
+		query.Del("page")
+		query.Set("after", page.NextToken)
+		query.Set("size", strconv.Itoa(page.Size))
+		links.Next = requestUrl.Path + "?" + query.Encode()
+	}
+
+	return links
+
 }
@@ -0,0 +1,45 @@
+package actuator
+
+import (
+	"MicroserviceTemplate/cmd/handler/actuator"
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// ? ==================== Interfaces ====================
+
+type IRouter interface {
+	GetRoutes(r *gin.Engine) *gin.Engine
+}
+
+// ? ==================== Structures ==================== ?
+
+type Router struct {
+	Handler   actuator.IHandler
+	AdminRole string
+}
+
+// ? ==================== Constructor ==================== ?
+
+// NewActuatorRouter returns a new actuator router, the operations that change the instance require the admin role
+func NewActuatorRouter(handler actuator.IHandler, security config.SecurityConfig) IRouter {
+	return &Router{handler, security.AdminRole}
+}
+
+// ? ===================== Methods ==================== ?
+
+// GetRoutes returns actuator routes
+func (router *Router) GetRoutes(r *gin.Engine) *gin.Engine {
+
+	routerActuator := r.Group("/actuator")
+
+	admin := middleware.HasRole(router.AdminRole)
+
+	routerActuator.POST("/refresh", admin, router.Handler.Refresh())
+	routerActuator.GET("/service-registry", router.Handler.ServiceRegistry())
+	routerActuator.POST("/service-registry", admin, router.Handler.SetServiceRegistryStatus())
+
+	return r
+
+}
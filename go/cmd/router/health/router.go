@@ -0,0 +1,40 @@
+package health
+
+import (
+	"MicroserviceTemplate/cmd/handler/health"
+	"github.com/gin-gonic/gin"
+)
+
+// ? ==================== Interfaces ====================
+
+type IRouter interface {
+	GetRoutes(r *gin.Engine) *gin.Engine
+}
+
+// ? ==================== Structures ==================== ?
+
+type Router struct {
+	Handler health.IHandler
+}
+
+// ? ==================== Constructor ==================== ?
+
+// NewHealthRouter returns a new health router
+func NewHealthRouter(handler health.IHandler) IRouter {
+	return &Router{handler}
+}
+
+// ? ===================== Methods ==================== ?
+
+// GetRoutes returns health routes
+func (router *Router) GetRoutes(r *gin.Engine) *gin.Engine {
+
+	routerHealth := r.Group("/health")
+
+	routerHealth.GET("", router.Handler.Health())
+	routerHealth.GET("/liveness", router.Handler.Liveness())
+	routerHealth.GET("/readiness", router.Handler.Readiness())
+
+	return r
+
+}
@@ -0,0 +1,517 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ? =========================== Structs =========================== ?
+
+// AppConfig is the configuration of the application bound from viper once the configuration is loaded. The settings
+// that can change at runtime, such as the database timeouts or the excluded paths, are bound again after a refresh.
+type AppConfig struct {
+	Application  ApplicationConfig  `mapstructure:"application"`
+	Server       ServerConfig       `mapstructure:"server"`
+	Database     DatabaseConfig     `mapstructure:"database"`
+	Keycloak     KeycloakConfig     `mapstructure:"keycloak"`
+	Security     SecurityConfig     `mapstructure:"security"`
+	Management   ManagementConfig   `mapstructure:"management"`
+	Pagination   PaginationConfig   `mapstructure:"pagination"`
+	Eureka       EurekaConfig       `mapstructure:"eureka"`
+	Consul       ConsulConfig       `mapstructure:"consul"`
+	Registry     RegistryConfig     `mapstructure:"registry"`
+	LoadBalancer LoadBalancerConfig `mapstructure:"loadbalancer"`
+}
+
+// * ============
+
+// ApplicationConfig is the name of the application and the location of its configuration in the config server
+type ApplicationConfig struct {
+	Name   string             `mapstructure:"name" validate:"required"`
+	Config ConfigServerConfig `mapstructure:"config"`
+}
+
+// * ============
+
+// ConfigServerConfig is the location of the config server, the credentials to access it and how it is retried
+type ConfigServerConfig struct {
+	Import   string              `mapstructure:"import" validate:"required,url"`
+	Profile  string              `mapstructure:"profile" validate:"required"`
+	Branch   string              `mapstructure:"branch"`
+	Username string              `mapstructure:"username"`
+	Password string              `mapstructure:"password" validate:"required_with=Username"`
+	Token    string              `mapstructure:"token"`
+	Offline  bool                `mapstructure:"offline"`
+	FailFast bool                `mapstructure:"fail-fast"`
+	Timeout  time.Duration       `mapstructure:"timeout" validate:"gt=0"`
+	Retry    ConfigRetryConfig   `mapstructure:"retry"`
+	Refresh  ConfigRefreshConfig `mapstructure:"refresh"`
+}
+
+// * ============
+
+// ConfigRetryConfig is the exponential backoff used while the config server is unavailable
+type ConfigRetryConfig struct {
+	MaxAttempts     int           `mapstructure:"max-attempts" validate:"gt=0"`
+	InitialInterval time.Duration `mapstructure:"initial-interval" validate:"gte=0"`
+	Multiplier      float64       `mapstructure:"multiplier" validate:"gte=1"`
+	MaxInterval     time.Duration `mapstructure:"max-interval" validate:"gte=0"`
+}
+
+// * ============
+
+// ConfigRefreshConfig is the polling of the config server, an interval of 0 disables it
+type ConfigRefreshConfig struct {
+	Interval time.Duration `mapstructure:"interval" validate:"gte=0"`
+}
+
+// * ============
+
+// ServerConfig is the HTTP server, port 0 listens on a random port
+type ServerConfig struct {
+	Port            int           `mapstructure:"port" validate:"gte=0,lte=65535"`
+	Hostname        string        `mapstructure:"hostname"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown-timeout" validate:"gt=0"`
+	SSL             SSLConfig     `mapstructure:"ssl"`
+}
+
+// * ============
+
+// SSLConfig is the certificate the HTTP server is served with when TLS is enabled
+type SSLConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	CertFile string `mapstructure:"cert-file" validate:"required_if=Enabled true"`
+	KeyFile  string `mapstructure:"key-file" validate:"required_if=Enabled true"`
+}
+
+// * ============
+
+// DatabaseConfig is the MongoDB deployment the products are stored in
+type DatabaseConfig struct {
+	Name     string                 `mapstructure:"name" validate:"required"`
+	Host     string                 `mapstructure:"host" validate:"required"`
+	Port     int                    `mapstructure:"port" validate:"gt=0,lte=65535"`
+	Username string                 `mapstructure:"username" validate:"required_with=Password"`
+	Password string                 `mapstructure:"password" validate:"required_with=Username"`
+	Pool     PoolConfig             `mapstructure:"pool"`
+	Timeouts DatabaseTimeoutsConfig `mapstructure:"timeouts"`
+}
+
+// * ============
+
+// DatabaseTimeoutsConfig bounds each operation of the repository, 0 falls back to the default timeout
+type DatabaseTimeoutsConfig struct {
+	Default time.Duration `mapstructure:"default" validate:"gt=0"`
+	Count   time.Duration `mapstructure:"count" validate:"gte=0"`
+	Find    time.Duration `mapstructure:"find" validate:"gte=0"`
+	FindOne time.Duration `mapstructure:"find-one" validate:"gte=0"`
+	Insert  time.Duration `mapstructure:"insert" validate:"gte=0"`
+	Update  time.Duration `mapstructure:"update" validate:"gte=0"`
+	Delete  time.Duration `mapstructure:"delete" validate:"gte=0"`
+}
+
+// * ============
+
+// PoolConfig is the connection pool of the MongoDB client, 0 keeps the default of the driver. It is only read when
+// connecting, so a change needs a restart.
+type PoolConfig struct {
+	MaxSize uint64 `mapstructure:"max-size"`
+}
+
+// * ============
+
+// KeycloakConfig is the Keycloak realm the access tokens are issued by
+type KeycloakConfig struct {
+	URL   string `mapstructure:"url" validate:"required,url"`
+	Realm string `mapstructure:"realm" validate:"required"`
+}
+
+// * ============
+
+// SecurityConfig is the authorization of the requests. The admin role guards the actuator operations that change the
+// instance, a change of it needs a restart.
+type SecurityConfig struct {
+	ExcludedPaths []string `mapstructure:"excluded-paths"`
+	AdminRole     string   `mapstructure:"admin-role" validate:"required"`
+}
+
+// * ============
+
+// ManagementConfig is the actuator of the application
+type ManagementConfig struct {
+	Health ManagementHealthConfig `mapstructure:"health"`
+}
+
+// * ============
+
+// ManagementHealthConfig is the health endpoint, every check is bounded by the timeout. The details of the components
+// are shown always, never or when-authorized, like management.endpoint.health.show-details of Spring Boot.
+type ManagementHealthConfig struct {
+	Timeout     time.Duration                   `mapstructure:"timeout" validate:"gt=0"`
+	ShowDetails string                          `mapstructure:"show-details" validate:"oneof=always never when-authorized"`
+	Readiness   ManagementHealthReadinessConfig `mapstructure:"readiness"`
+}
+
+// * ============
+
+// ManagementHealthReadinessConfig is the readiness of the instance, which is also its status in the service registry.
+// The components excluded are remote dependencies whose outage should not take every instance out of service.
+type ManagementHealthReadinessConfig struct {
+	Exclude []string `mapstructure:"exclude"`
+}
+
+// * ============
+
+// PaginationConfig is the signing of the continuation tokens of the keyset pagination. The secret is shared by every
+// replica, so it has no default, only offline mode generates one for the process.
+type PaginationConfig struct {
+	TokenSecret string `mapstructure:"token-secret" validate:"required"`
+}
+
+// * ============
+
+// EurekaConfig is the Eureka server the instance registers in
+type EurekaConfig struct {
+	Client   EurekaClientConfig   `mapstructure:"client"`
+	Instance EurekaInstanceConfig `mapstructure:"instance"`
+}
+
+// * ============
+
+// EurekaClientConfig is the client side of the Eureka configuration
+type EurekaClientConfig struct {
+	ServiceURL                   EurekaServiceURLConfig  `mapstructure:"service-url"`
+	Region                       string                  `mapstructure:"region"`
+	AvailabilityZones            map[string]string       `mapstructure:"availability-zones"`
+	PreferSameZoneEureka         bool                    `mapstructure:"prefer-same-zone-eureka"`
+	FetchRegistry                bool                    `mapstructure:"fetch-registry"`
+	RegistryFetchIntervalSeconds int                     `mapstructure:"registry-fetch-interval-seconds" validate:"gt=0"`
+	DisableDelta                 bool                    `mapstructure:"disable-delta"`
+	FilterOnlyUpInstances        bool                    `mapstructure:"filter-only-up-instances"`
+	Healthcheck                  EurekaHealthcheckConfig `mapstructure:"healthcheck"`
+}
+
+// * ============
+
+// EurekaHealthcheckConfig makes the status of the instance in Eureka follow its readiness
+type EurekaHealthcheckConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// * ============
+
+// EurekaInstanceConfig is the instance registered in Eureka. The hostname defaults to server.hostname, then to the name
+// of the machine, and the IP address to the one of the interface of the outbound traffic.
+type EurekaInstanceConfig struct {
+	Hostname                         string            `mapstructure:"hostname"`
+	IPAddress                        string            `mapstructure:"ip-address" validate:"omitempty,ip"`
+	PreferIPAddress                  bool              `mapstructure:"prefer-ip-address"`
+	LeaseRenewalIntervalInSeconds    int               `mapstructure:"lease-renewal-interval-in-seconds" validate:"gt=0"`
+	LeaseExpirationDurationInSeconds int               `mapstructure:"lease-expiration-duration-in-seconds" validate:"gtfield=LeaseRenewalIntervalInSeconds"`
+	MetadataMap                      map[string]string `mapstructure:"metadata-map"`
+}
+
+// * ============
+
+// EurekaServiceURLConfig is the Eureka servers of each zone, separated by commas. The zones other than defaultZone are
+// named in availability-zones.
+type EurekaServiceURLConfig struct {
+	DefaultZone string            `mapstructure:"defaultZone" validate:"required,url_list"`
+	Zones       map[string]string `mapstructure:",remain"`
+}
+
+// * ============
+
+// ConsulConfig is the Consul agent the instance registers in when the registry is consul
+type ConsulConfig struct {
+	URL       string                `mapstructure:"url" validate:"required,url"`
+	Token     string                `mapstructure:"token"`
+	Discovery ConsulDiscoveryConfig `mapstructure:"discovery"`
+}
+
+// * ============
+
+// ConsulDiscoveryConfig is the service registered in Consul. The hostname and the IP address are resolved like the ones
+// of Eureka, and the TTL check must be passed by a heartbeat before it expires.
+type ConsulDiscoveryConfig struct {
+	Hostname                       string            `mapstructure:"hostname"`
+	IPAddress                      string            `mapstructure:"ip-address" validate:"omitempty,ip"`
+	PreferIPAddress                bool              `mapstructure:"prefer-ip-address"`
+	Metadata                       map[string]string `mapstructure:"metadata"`
+	HealthCheckTTL                 time.Duration     `mapstructure:"health-check-ttl" validate:"gt=0"`
+	DeregisterCriticalServiceAfter time.Duration     `mapstructure:"deregister-critical-service-after" validate:"gte=0"`
+}
+
+// * ============
+
+// RegistryConfig is the service registry the instance registers in and finds the other services in
+type RegistryConfig struct {
+	Type string `mapstructure:"type" validate:"oneof=eureka consul memory"`
+}
+
+// * ============
+
+// LoadBalancerConfig is the client side load balancing of the requests to the services registered in Eureka
+type LoadBalancerConfig struct {
+	Strategy         string        `mapstructure:"strategy" validate:"oneof=round-robin random zone-preference"`
+	Zone             string        `mapstructure:"zone" validate:"required_if=Strategy zone-preference"`
+	MaxAttempts      int           `mapstructure:"max-attempts" validate:"gt=0"`
+	EvictionDuration time.Duration `mapstructure:"eviction-duration" validate:"gte=0"`
+}
+
+// ? =========================== Constructors =========================== ?
+
+// NewAppConfig binds the loaded configuration with its defaults and validates it. The error lists every missing or
+// invalid key at once.
+func NewAppConfig() (*AppConfig, error) {
+
+	setConfigServerDefaults()
+
+	viper.SetDefault("server.port", 0)
+	viper.SetDefault("server.shutdown-timeout", 20*time.Second)
+	viper.SetDefault("database.name", "microservice_go_template")
+	viper.SetDefault("database.host", "localhost")
+	viper.SetDefault("database.port", 27017)
+	viper.SetDefault("database.timeouts.default", 5*time.Second)
+	viper.SetDefault("security.admin-role", "ADMIN")
+	viper.SetDefault("management.health.timeout", 5*time.Second)
+	viper.SetDefault("management.health.show-details", "when-authorized")
+	viper.SetDefault("management.health.readiness.exclude", []string{"configServer", "keycloak"})
+	viper.SetDefault("eureka.client.service-url.defaultZone", "http://localhost:8761/eureka")
+	viper.SetDefault("eureka.client.region", "us-east-1")
+	viper.SetDefault("eureka.client.prefer-same-zone-eureka", true)
+	viper.SetDefault("eureka.client.fetch-registry", true)
+	viper.SetDefault("eureka.client.registry-fetch-interval-seconds", 30)
+	viper.SetDefault("eureka.client.filter-only-up-instances", true)
+	viper.SetDefault("eureka.client.healthcheck.enabled", true)
+	viper.SetDefault("eureka.instance.lease-renewal-interval-in-seconds", 30)
+	viper.SetDefault("eureka.instance.lease-expiration-duration-in-seconds", 90)
+	viper.SetDefault("consul.url", "http://localhost:8500")
+	viper.SetDefault("consul.discovery.health-check-ttl", 30*time.Second)
+	viper.SetDefault("consul.discovery.deregister-critical-service-after", time.Minute)
+	viper.SetDefault("registry.type", "eureka")
+	viper.SetDefault("loadbalancer.strategy", "round-robin")
+	viper.SetDefault("loadbalancer.max-attempts", 2)
+	viper.SetDefault("loadbalancer.eviction-duration", 30*time.Second)
+
+	// The keys that are in no file are bound too, so they can be given only through the environment
+	bindKeys("", reflect.TypeOf(AppConfig{}))
+
+	var problems []string
+	var appConfig AppConfig
+
+	// Every key is decoded even when some of them fail, so the decoding errors are listed together
+	err := viper.Unmarshal(&appConfig)
+
+	var decodeErrors *mapstructure.Error
+	if errors.As(err, &decodeErrors) {
+		problems = append(problems, decodeErrors.Errors...)
+	} else if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	problems = append(problems, validateAppConfig(&appConfig)...)
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("the application configuration is invalid:\n - %s", strings.Join(problems, "\n - "))
+	}
+
+	return &appConfig, nil
+
+}
+
+// * ============
+
+// NewSections returns the sections of the configuration, so each component receives only the one it needs
+func NewSections(appConfig *AppConfig) (ApplicationConfig, ServerConfig, DatabaseConfig, KeycloakConfig, SecurityConfig, ManagementConfig, PaginationConfig, EurekaConfig, ConsulConfig, RegistryConfig, LoadBalancerConfig) {
+	return appConfig.Application, appConfig.Server, appConfig.Database, appConfig.Keycloak, appConfig.Security, appConfig.Management, appConfig.Pagination, appConfig.Eureka, appConfig.Consul, appConfig.Registry, appConfig.LoadBalancer
+}
+
+// ? =========================== Functions =========================== ?
+
+// bindConfigServerConfig binds application.config alone, the config client needs it before the rest of the
+// configuration is loaded from the config server and can be validated
+func bindConfigServerConfig() (ConfigServerConfig, error) {
+
+	setConfigServerDefaults()
+	bindKeys("application.config", reflect.TypeOf(ConfigServerConfig{}))
+
+	var settings struct {
+		Application struct {
+			Config ConfigServerConfig `mapstructure:"config"`
+		} `mapstructure:"application"`
+	}
+
+	err := viper.Unmarshal(&settings)
+
+	return settings.Application.Config, err
+
+}
+
+// * ============
+
+// setConfigServerDefaults sets the defaults of the config client, the ones of Spring Cloud Config: 6 attempts starting
+// at 1s, multiplied by 1.1 up to 2s
+func setConfigServerDefaults() {
+	viper.SetDefault("application.config.timeout", 10*time.Second)
+	viper.SetDefault("application.config.retry.max-attempts", 6)
+	viper.SetDefault("application.config.retry.initial-interval", time.Second)
+	viper.SetDefault("application.config.retry.multiplier", 1.1)
+	viper.SetDefault("application.config.retry.max-interval", 2*time.Second)
+}
+
+// * ============
+
+// validateAppConfig lists the keys that break the rules declared in the validate tags
+func validateAppConfig(appConfig *AppConfig) []string {
+
+	v := validator.New()
+
+	_ = v.RegisterValidation("url_list", func(fl validator.FieldLevel) bool {
+		return isURLList(fl.Field().String())
+	})
+
+	// The keys are reported the way they are written in the configuration files
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		return name
+	})
+
+	var problems []string
+
+	// The zones are keys of a map, which the validate tags cannot name
+	for zone, urls := range appConfig.Eureka.Client.ServiceURL.Zones {
+		if !isURLList(urls) {
+			problems = append(problems, fmt.Sprintf("eureka.client.service-url.%s must be a list of URLs separated by commas, got %q", zone, urls))
+		}
+	}
+
+	err := v.Struct(appConfig)
+	if err == nil {
+		return problems
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return append(problems, err.Error())
+	}
+
+	for _, fieldError := range validationErrors {
+
+		// The namespace starts with the name of the root struct, which is not part of the key
+		key := fieldError.Namespace()
+		if i := strings.Index(key, "."); i >= 0 {
+			key = key[i+1:]
+		}
+
+		problems = append(problems, keyProblem(key, fieldError))
+
+	}
+
+	return problems
+
+}
+
+// * ============
+
+// bindKeys binds every key of the struct to its environment variable
+func bindKeys(prefix string, t reflect.Type) {
+
+	for i := 0; i < t.NumField(); i++ {
+
+		field := t.Field(i)
+
+		key := field.Tag.Get("mapstructure")
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			bindKeys(key, field.Type)
+			continue
+		}
+
+		// The keys of the maps are not known in advance
+		if field.Type.Kind() == reflect.Map {
+			continue
+		}
+
+		_ = viper.BindEnv(key)
+
+	}
+
+}
+
+// * ============
+
+// isURLList reports whether the text is a list of absolute URLs separated by commas
+func isURLList(text string) bool {
+
+	for _, item := range strings.Split(text, ",") {
+		parsed, err := url.Parse(strings.TrimSpace(item))
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+// * ============
+
+// kebabCase turns the name of a field into its key, LeaseRenewalIntervalInSeconds into lease-renewal-interval-in-seconds
+func kebabCase(name string) string {
+
+	var key strings.Builder
+
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 {
+			key.WriteByte('-')
+		}
+		key.WriteRune(unicode.ToLower(r))
+	}
+
+	return key.String()
+
+}
+
+// * ============
+
+// keyProblem describes the rule broken by the key
+func keyProblem(key string, fieldError validator.FieldError) string {
+
+	switch fieldError.Tag() {
+	case "required":
+		return key + " is required"
+	case "required_with":
+		return fmt.Sprintf("%s is required when %s is set", key, strings.ToLower(fieldError.Param()))
+	case "url":
+		return fmt.Sprintf("%s must be a URL, got %q", key, fieldError.Value())
+	case "url_list":
+		return fmt.Sprintf("%s must be a list of URLs separated by commas, got %q", key, fieldError.Value())
+	case "required_if":
+		return fmt.Sprintf("%s is required when %s", key, strings.ToLower(strings.Replace(fieldError.Param(), " ", " is ", 1)))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s, got %v", key, fieldError.Param(), fieldError.Value())
+	case "gt", "gte", "lte":
+		return fmt.Sprintf("%s must be %s %s, got %v", key, fieldError.Tag(), fieldError.Param(), fieldError.Value())
+	case "gtfield":
+		return fmt.Sprintf("%s must be greater than %s, got %v", key, kebabCase(fieldError.Param()), fieldError.Value())
+	case "ip":
+		return fmt.Sprintf("%s must be an IP address, got %q", key, fieldError.Value())
+	default:
+		return fmt.Sprintf("%s is invalid (%s), got %v", key, fieldError.Tag(), fieldError.Value())
+	}
+
+}
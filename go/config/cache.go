@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ? =========================== Structs =========================== ?
+
+// cachedConfiguration is the last configuration fetched from the config server. The values are kept as the server sent
+// them, so the {cipher} values are not written to the disk in plain text.
+type cachedConfiguration struct {
+	URL       string            `json:"url"`
+	FetchedAt time.Time         `json:"fetchedAt"`
+	Config    springCloudConfig `json:"config"`
+}
+
+// ? =========================== Functions =========================== ?
+
+// isCacheEnabled reports whether the configuration fetched from the config server is kept as the last known good one
+func isCacheEnabled() bool {
+	viper.SetDefault("application.config.cache.enabled", true)
+	return viper.GetBool("application.config.cache.enabled")
+}
+
+// * ============
+
+// cachePath returns the file the configuration of the source is cached in
+func cachePath(source *remoteSource) string {
+
+	viper.SetDefault("application.config.cache.dir", ".config-cache")
+
+	name := fmt.Sprintf("%s-%s-%s.json", source.appName, source.profile, source.branch)
+
+	return filepath.Join(viper.GetString("application.config.cache.dir"), filepath.Base(name))
+
+}
+
+// * ============
+
+// saveCache writes the configuration to the cache of the source. The file is replaced by a rename, so a crash while
+// writing never leaves a truncated cache behind.
+func saveCache(source *remoteSource, url string, cloudConfig *springCloudConfig) error {
+
+	if !isCacheEnabled() {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(cachedConfiguration{url, time.Now().UTC(), *cloudConfig}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := cachePath(source)
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = os.Remove(temp.Name())
+	}()
+
+	_, err = temp.Write(content)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+
+}
+
+// * ============
+
+// readCache reads the cached configuration of the source
+func readCache(source *remoteSource) (*cachedConfiguration, error) {
+
+	if !isCacheEnabled() {
+		return nil, fmt.Errorf("the configuration cache is disabled")
+	}
+
+	content, err := os.ReadFile(cachePath(source))
+	if err != nil {
+		return nil, err
+	}
+
+	var cached cachedConfiguration
+
+	err = json.Unmarshal(content, &cached)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse the cached configuration: %w", err)
+	}
+
+	return &cached, nil
+
+}
@@ -0,0 +1,379 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/pbkdf2"
+	"log"
+	"os"
+	"strings"
+)
+
+// ? =========================== Interfaces =========================== ?
+
+// iDecryptor decrypts the values stored as {cipher}... in the config repository
+type iDecryptor interface {
+	Decrypt(cipherText string) (string, error)
+}
+
+// ? =========================== Structs =========================== ?
+
+// aesDecryptor decrypts the values encrypted with a symmetric key, the same way as Spring Security's
+// Encryptors.text: hex(iv + AES-256-CBC(value)) with the key derived by PBKDF2WithHmacSHA1 from the password and salt
+type aesDecryptor struct {
+	key []byte
+}
+
+// * ============
+
+// rsaDecryptor decrypts the values encrypted with an RSA key pair, the same way as Spring Security's
+// RsaSecretEncryptor: base64(length + RSA(secret) + AES(value)), where the value is encrypted with the secret
+type rsaDecryptor struct {
+	key    *rsa.PrivateKey
+	oaep   bool
+	salt   string
+	strong bool
+}
+
+// ? =========================== Constants =========================== ?
+
+const (
+	cipherPrefix = "{cipher}"
+
+	// defaultSalt is the salt used by Spring Cloud Config when encrypt.salt is not set
+	defaultSalt = "deadbeef"
+
+	// pbkdf2Iterations and aesKeyLength are the parameters of Spring Security's AesBytesEncryptor
+	pbkdf2Iterations = 1024
+	aesKeyLength     = 32
+)
+
+// ? =========================== Variables =========================== ?
+
+// ErrDecryption is returned when a {cipher} value cannot be decrypted with the configured key
+var ErrDecryption = errors.New("cannot decrypt the configuration value")
+
+// ? =========================== Constructors =========================== ?
+
+// newDecryptor returns the decryptor of the key in encrypt.key or the file in encrypt.key-file, which can be given with
+// the ENCRYPT_KEY and ENCRYPT_KEY_FILE environment variables too. A PEM key selects RSA, any other key selects AES.
+// It returns nil when no key is configured.
+func newDecryptor() (iDecryptor, error) {
+
+	key := firstNonEmpty(os.Getenv("ENCRYPT_KEY"), viper.GetString("encrypt.key"))
+
+	if keyFile := firstNonEmpty(os.Getenv("ENCRYPT_KEY_FILE"), viper.GetString("encrypt.key-file")); key == "" && keyFile != "" {
+
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the encryption key file: %w", err)
+		}
+
+		key = strings.TrimSpace(string(content))
+
+	}
+
+	if key == "" {
+		return nil, nil
+	}
+
+	salt := firstNonEmpty(viper.GetString("encrypt.salt"), defaultSalt)
+
+	if strings.HasPrefix(key, "-----BEGIN") {
+		return newRSADecryptor(key, firstNonEmpty(viper.GetString("encrypt.rsa.salt"), defaultSalt))
+	}
+
+	return newAESDecryptor(key, salt)
+
+}
+
+// * ============
+
+// newAESDecryptor returns a decryptor of the values encrypted with the password and the hex encoded salt
+func newAESDecryptor(password string, salt string) (iDecryptor, error) {
+
+	key, err := deriveKey(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aesDecryptor{key}, nil
+
+}
+
+// * ============
+
+// newRSADecryptor returns a decryptor of the values encrypted with the public key of the PEM encoded private key
+func newRSADecryptor(pemKey string, salt string) (iDecryptor, error) {
+
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("the encryption key is not a valid PEM block")
+	}
+
+	var privateKey *rsa.PrivateKey
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse the RSA private key: %w", err)
+		}
+
+		privateKey = key
+
+	case "PRIVATE KEY":
+
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse the private key: %w", err)
+		}
+
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("the private key is not an RSA key")
+		}
+
+		privateKey = rsaKey
+
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %s, expected an RSA private key", block.Type)
+	}
+
+	return &rsaDecryptor{
+		key:    privateKey,
+		oaep:   strings.EqualFold(viper.GetString("encrypt.rsa.algorithm"), "OAEP"),
+		salt:   salt,
+		strong: viper.GetBool("encrypt.rsa.strong"),
+	}, nil
+
+}
+
+// ? =========================== Functions =========================== ?
+
+// decryptProperties replaces the {cipher} values of the property sources with their plain text. The values decrypted
+// by the config server arrive in plain text and are kept as they are. When no key is configured the {cipher} values
+// are kept too, and when a value cannot be decrypted the load fails unless encrypt.fail-on-error is false.
+func decryptProperties(sources []propertySource) error {
+
+	viper.SetDefault("encrypt.fail-on-error", true)
+
+	var decryptor iDecryptor
+
+	for _, source := range sources {
+		for key, value := range source.Source {
+
+			text, ok := value.(string)
+			if !ok || !strings.HasPrefix(text, cipherPrefix) {
+				continue
+			}
+
+			if decryptor == nil {
+
+				d, err := newDecryptor()
+				if err != nil {
+					return err
+				}
+
+				if d == nil {
+					log.Printf("no encryption key configured, %s of %s is kept encrypted\n", key, source.Name)
+					continue
+				}
+
+				decryptor = d
+
+			}
+
+			plain, err := decryptor.Decrypt(strings.TrimPrefix(text, cipherPrefix))
+			if err != nil {
+
+				if viper.GetBool("encrypt.fail-on-error") {
+					return fmt.Errorf("%s of %s: %w", key, source.Name, err)
+				}
+
+				log.Printf("cannot decrypt %s of %s, it is left empty. error: %s\n", key, source.Name, err.Error())
+				plain = ""
+
+			}
+
+			source.Source[key] = plain
+
+		}
+	}
+
+	return nil
+
+}
+
+// * ============
+
+// deriveKey derives the AES key from the password and the hex encoded salt like Spring Security's AesBytesEncryptor
+func deriveKey(password string, salt string) ([]byte, error) {
+
+	saltBytes, err := hex.DecodeString(salt)
+	if err != nil {
+		return nil, fmt.Errorf("the encryption salt must be hex encoded: %w", err)
+	}
+
+	return pbkdf2.Key([]byte(password), saltBytes, pbkdf2Iterations, aesKeyLength, sha1.New), nil
+
+}
+
+// * ============
+
+// decryptCBC decrypts iv + AES-CBC(value) with PKCS#5 padding
+func decryptCBC(key []byte, data []byte) ([]byte, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, ErrDecryption
+	}
+
+	iv, cipherText := data[:aes.BlockSize], data[aes.BlockSize:]
+
+	plain := make([]byte, len(cipherText))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, cipherText)
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, ErrDecryption
+	}
+
+	for _, b := range plain[len(plain)-padding:] {
+		if int(b) != padding {
+			return nil, ErrDecryption
+		}
+	}
+
+	return plain[:len(plain)-padding], nil
+
+}
+
+// * ============
+
+// decryptGCM decrypts iv + AES-GCM(value), the format of Spring Security's stronger encryptor
+func decryptGCM(key []byte, data []byte) ([]byte, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aes.BlockSize {
+		return nil, ErrDecryption
+	}
+
+	plain, err := gcm.Open(nil, data[:aes.BlockSize], data[aes.BlockSize:], nil)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+
+	return plain, nil
+
+}
+
+// * ============
+
+// firstNonEmpty returns the first value that is not empty
+func firstNonEmpty(values ...string) string {
+
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+
+}
+
+// ? =========================== Methods =========================== ?
+
+// Decrypt decrypts the hex encoded cipher text
+func (ad *aesDecryptor) Decrypt(cipherText string) (string, error) {
+
+	data, err := hex.DecodeString(cipherText)
+	if err != nil {
+		return "", fmt.Errorf("%w: the cipher text is not hex encoded", ErrDecryption)
+	}
+
+	plain, err := decryptCBC(ad.key, data)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plain), nil
+
+}
+
+// * ============
+
+// Decrypt decrypts the base64 encoded cipher text
+func (rd *rsaDecryptor) Decrypt(cipherText string) (string, error) {
+
+	data, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return "", fmt.Errorf("%w: the cipher text is not base64 encoded", ErrDecryption)
+	}
+
+	if len(data) < 2 {
+		return "", ErrDecryption
+	}
+
+	length := int(data[0])<<8 | int(data[1])
+	if len(data) < 2+length {
+		return "", ErrDecryption
+	}
+
+	encryptedSecret, encryptedValue := data[2:2+length], data[2+length:]
+
+	var secret []byte
+	if rd.oaep {
+		secret, err = rsa.DecryptOAEP(sha1.New(), nil, rd.key, encryptedSecret, nil)
+	} else {
+		secret, err = rsa.DecryptPKCS1v15(nil, rd.key, encryptedSecret)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrDecryption, err.Error())
+	}
+
+	// The secret is used as the password of the AES encryptor in its hex form
+	key, err := deriveKey(hex.EncodeToString(secret), rd.salt)
+	if err != nil {
+		return "", err
+	}
+
+	var plain []byte
+	if rd.strong {
+		plain, err = decryptGCM(key, encryptedValue)
+	} else {
+		plain, err = decryptCBC(key, encryptedValue)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(plain), nil
+
+}
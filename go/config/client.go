@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ? =========================== Structs =========================== ?
+
+// statusError is the response of the config server with a status other than 2xx
+type statusError struct {
+	url        string
+	statusCode int
+}
+
+// * ============
+
+// retryPolicy is the exponential backoff used while the config server is unavailable, with the defaults of Spring
+// Cloud Config: 6 attempts starting at 1s, multiplied by 1.1 up to 2s
+type retryPolicy struct {
+	maxAttempts     int
+	initialInterval time.Duration
+	multiplier      float64
+	maxInterval     time.Duration
+}
+
+// * ============
+
+// configClient requests the configuration from the config server
+type configClient struct {
+	http     *http.Client
+	username string
+	password string
+	token    string
+	retry    retryPolicy
+}
+
+// ? =========================== Variables =========================== ?
+
+// ErrConfigNotFound is returned when the config server has no configuration for the application
+var ErrConfigNotFound = errors.New("the config server has no configuration for the application")
+
+// ? =========================== Constructors =========================== ?
+
+// newConfigClient returns a client with the settings of application.config
+func newConfigClient(configServer ConfigServerConfig) *configClient {
+	return &configClient{
+		http:     &http.Client{Timeout: configServer.Timeout},
+		username: configServer.Username,
+		password: configServer.Password,
+		token:    configServer.Token,
+		retry: retryPolicy{
+			maxAttempts:     configServer.Retry.MaxAttempts,
+			initialInterval: configServer.Retry.InitialInterval,
+			multiplier:      configServer.Retry.Multiplier,
+			maxInterval:     configServer.Retry.MaxInterval,
+		},
+	}
+}
+
+// ? =========================== Functions =========================== ?
+
+// isRetryable reports whether the request may succeed if it is sent again
+func isRetryable(err error) bool {
+
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.statusCode >= http.StatusInternalServerError ||
+			status.statusCode == http.StatusRequestTimeout ||
+			status.statusCode == http.StatusTooManyRequests
+	}
+
+	return !errors.Is(err, ErrConfigNotFound)
+
+}
+
+// ? =========================== Methods =========================== ?
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("the config server answered %s with status %d", e.url, e.statusCode)
+}
+
+// * ============
+
+// fetch requests the url until it answers, the error is not retryable or the attempts run out
+func (cc *configClient) fetch(url string) ([]byte, error) {
+
+	attempts := cc.retry.maxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	interval := cc.retry.initialInterval
+
+	var err error
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+
+		var body []byte
+
+		body, err = cc.get(url)
+		if err == nil {
+			return body, nil
+		}
+
+		if !isRetryable(err) || attempt == attempts {
+			break
+		}
+
+		log.Printf("couldn't fetch the configuration (attempt %d of %d), retrying in %s. error: %s\n", attempt, attempts, interval, err.Error())
+
+		time.Sleep(interval)
+
+		interval = time.Duration(float64(interval) * cc.retry.multiplier)
+		if cc.retry.maxInterval > 0 && interval > cc.retry.maxInterval {
+			interval = cc.retry.maxInterval
+		}
+
+	}
+
+	return nil, err
+
+}
+
+// * ============
+
+// get sends a single request with the credentials of the client
+func (cc *configClient) get(url string) ([]byte, error) {
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if cc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cc.token)
+	} else if cc.username != "" {
+		req.SetBasicAuth(cc.username, cc.password)
+	}
+
+	resp, err := cc.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrConfigNotFound, url)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &statusError{url, resp.StatusCode}
+	}
+
+	return io.ReadAll(resp.Body)
+
+}
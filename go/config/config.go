@@ -1,12 +1,16 @@
 package config
 
 import (
+	"MicroserviceTemplate/pkg/metrics"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/spf13/viper"
-	"io"
 	"log"
-	"net/http"
+	"sync"
+	"time"
 )
 
 // ? =========================== Structs =========================== ?
@@ -28,54 +32,328 @@ type propertySource struct {
 	Source map[string]interface{} `json:"source"`
 }
 
+// * ============
+
+// remoteSource is the location of the configuration in the config server
+type remoteSource struct {
+	configServerUrl string
+	appName         string
+	profile         string
+	branch          string
+}
+
+// * ============
+
+// settingsLayers keeps every layer of configuration apart, so they can be laid over each other again when one changes
+type settingsLayers struct {
+	mu      sync.Mutex
+	source  *remoteSource
+	flags   map[string]string
+	local   map[string]interface{}
+	remote  map[string]interface{}
+	applied map[string]interface{}
+}
+
+// ? =========================== Variables =========================== ?
+
+// layers is the configuration the global viper instance is built from
+var layers = &settingsLayers{
+	flags:  map[string]string{},
+	local:  map[string]interface{}{},
+	remote: map[string]interface{}{},
+}
+
 // ? =========================== Functions =========================== ?
 
-// LoadConfigurationFromBranch loads configuration from config server
+// LoadLocalConfiguration loads the application.yml file of the path as the base configuration and the
+// application-{profile}.yml files of the active profiles over it. The environment variables take precedence over both.
+// It starts a new load, so the configuration previously loaded from the config server is dropped.
+func LoadLocalConfiguration(path string) error {
+
+	bindEnvironment()
+
+	local, err := readLocalFile(path, "application")
+	if err != nil {
+		return err
+	}
+
+	layers.mu.Lock()
+	defer layers.mu.Unlock()
+
+	layers.local = local
+	layers.remote = map[string]interface{}{}
+	layers.source = nil
+
+	// The active profiles may come from the base file, the environment or the command line
+	err = layers.apply()
+	if err != nil {
+		return err
+	}
+
+	for _, profile := range ActiveProfiles() {
+
+		profileSettings, err := readLocalFile(path, "application-"+profile)
+
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		log.Printf("loading the local configuration of profile %s\n", profile)
+		mergeProperties(layers.local, profileSettings)
+
+	}
+
+	return layers.apply()
+
+}
+
+// * ============
+
+// readLocalFile reads the yaml file of the path with the name
+func readLocalFile(path string, name string) (map[string]interface{}, error) {
+
+	vp := viper.New()
+
+	vp.SetConfigName(name)
+	vp.SetConfigType("yaml")
+	vp.AddConfigPath(path)
+
+	err := vp.ReadInConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return vp.AllSettings(), nil
+
+}
+
+// * ============
+
+// LoadConfigurationFromBranch loads configuration from config server. When the config server cannot be reached the
+// last configuration fetched from it is used, and the application stops when there is none and fail-fast is enabled.
+// In offline mode only the local configuration is used.
 func LoadConfigurationFromBranch(configServerUrl string, appName string, profile string, branch string) {
 
-	url := fmt.Sprintf("%s/%s/%s/%s", configServerUrl, appName, profile, branch)
+	if isOffline() {
+		log.Println("offline mode, the configuration is only loaded from the local files")
+		metrics.RecordConfigSource(metrics.ConfigSourceLocal)
+		useOfflineTokenSecret()
+		return
+	}
+
+	layers.mu.Lock()
+	layers.source = &remoteSource{configServerUrl, appName, profile, branch}
+	layers.mu.Unlock()
+
+	err := loadRemoteConfiguration()
+	metrics.RecordConfigRefresh(err)
+
+	if err == nil {
+		return
+	}
+
+	cached, cacheErr := loadCachedConfiguration()
+	if cacheErr == nil {
+		log.Printf("WARNING: couldn't load configuration from the config server, starting with the cached configuration "+
+			"fetched from %s at %s (version %s, label %s). error: %s\n",
+			cached.URL, cached.FetchedAt.Format(time.RFC3339), cached.Config.Version, cached.Config.Label, err.Error())
+		return
+	}
+
+	log.Printf("couldn't load the cached configuration. error: %s\n", cacheErr.Error())
+
+	// A configuration of the config client that cannot be read is not taken as permission to start without it
+	configServer, bindErr := bindConfigServerConfig()
+	if bindErr != nil || configServer.FailFast {
+		log.Fatalln("couldn't load configuration, cannot start. terminating. error: " + err.Error())
+	}
+
+	log.Printf("couldn't load configuration, starting with the local configuration. error: %s\n", err.Error())
+	metrics.RecordConfigSource(metrics.ConfigSourceLocal)
+
+}
+
+// * ============
+
+// loadRemoteConfiguration fetches the configuration from the config server and lays it over the local configuration
+func loadRemoteConfiguration() error {
+
+	layers.mu.Lock()
+	defer layers.mu.Unlock()
+
+	if isOffline() {
+		return errors.New("the configuration is not loaded from a config server in offline mode")
+	}
+
+	if layers.source == nil {
+		return errors.New("the configuration has not been loaded from a config server")
+	}
+
+	url := fmt.Sprintf("%s/%s/%s/%s", layers.source.configServerUrl, layers.source.appName, layers.source.profile, layers.source.branch)
 	log.Printf("loading config from %s\n", url)
 
-	body, err := fetchConfiguration(url)
+	configServer, err := bindConfigServerConfig()
 	if err != nil {
-		log.Fatalln("couldn't load configuration, cannot start. terminating. error: " + err.Error())
+		return err
 	}
 
-	parseConfiguration(body)
+	body, err := newConfigClient(configServer).fetch(url)
+	if err != nil {
+		return err
+	}
+
+	cloudConfig, err := parseConfiguration(body)
+	if err != nil {
+		return err
+	}
+
+	// The cache is written before decrypting, so it keeps the values as the config server sent them
+	err = saveCache(layers.source, url, cloudConfig)
+	if err != nil {
+		log.Printf("couldn't cache the configuration. error: %s\n", err.Error())
+	}
+
+	err = layers.applyRemote(cloudConfig)
+	if err != nil {
+		return err
+	}
+
+	metrics.RecordConfigSource(metrics.ConfigSourceServer)
+
+	return nil
 
 }
 
 // * ============
 
-// FetchConfiguration fetches configuration from config server
-func fetchConfiguration(url string) ([]byte, error) {
+// loadCachedConfiguration lays the last configuration fetched from the config server over the local configuration
+func loadCachedConfiguration() (*cachedConfiguration, error) {
 
-	resp, err := http.Get(url)
+	layers.mu.Lock()
+	defer layers.mu.Unlock()
+
+	cached, err := readCache(layers.source)
 	if err != nil {
-		log.Fatalln("couldn't load configuration, cannot start. terminating. error: " + err.Error())
+		return nil, err
 	}
-	body, err := io.ReadAll(resp.Body)
-	return body, err
+
+	err = layers.applyRemote(&cached.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.RecordConfigSource(metrics.ConfigSourceCache)
+
+	return cached, nil
+
+}
+
+// * ============
+
+// useOfflineTokenSecret signs the continuation tokens with a random secret of the process when offline mode has none,
+// so local development only needs resources/application.yml. The tokens are then only valid on this process.
+func useOfflineTokenSecret() {
+
+	if viper.GetString("pagination.token-secret") != "" {
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Printf("couldn't generate a pagination.token-secret for offline mode. error: %s\n", err.Error())
+		return
+	}
+
+	viper.Set("pagination.token-secret", hex.EncodeToString(secret))
+
+	log.Println("WARNING: pagination.token-secret is not set, offline mode signs the continuation tokens with a random " +
+		"secret that other instances and restarts do not accept")
+
+}
+
+// * ============
+
+// isOffline reports whether the configuration is only loaded from the local files, which is meant for local development
+func isOffline() bool {
+	return viper.GetBool("application.config.offline")
 }
 
 // * ============
 
 // ParseConfiguration parses configuration from config server
-func parseConfiguration(body []byte) {
+func parseConfiguration(body []byte) (*springCloudConfig, error) {
 
 	var cloudConfig springCloudConfig
 
 	err := json.Unmarshal(body, &cloudConfig)
 	if err != nil {
-		log.Fatalln("cannot parse configuration, message: " + err.Error())
+		return nil, fmt.Errorf("cannot parse configuration, message: %w", err)
+	}
+
+	return &cloudConfig, nil
+
+}
+
+// ? =========================== Methods =========================== ?
+
+// applyRemote decrypts the configuration of the config server and lays it over the local configuration
+func (sl *settingsLayers) applyRemote(cloudConfig *springCloudConfig) error {
+
+	if len(cloudConfig.PropertySources) == 0 {
+		log.Printf("no property sources found for service %s, keeping the local configuration\n", cloudConfig.Name)
+	}
+
+	err := decryptProperties(cloudConfig.PropertySources)
+	if err != nil {
+		return err
+	}
+
+	sl.remote = mergePropertySources(cloudConfig.PropertySources)
+
+	err = sl.apply()
+	if err != nil {
+		return err
+	}
+
+	if cloudConfig.Name != "" && len(cloudConfig.PropertySources) > 0 {
+		log.Printf("successfully loaded configuration for service %s from %d property sources\n", cloudConfig.Name, len(cloudConfig.PropertySources))
 	}
 
-	for key, value := range cloudConfig.PropertySources[0].Source {
-		viper.Set(key, value)
+	return nil
+
+}
+
+// * ============
+
+// apply lays the remote configuration over the local one into viper, below the environment and the command line, and
+// resolves their placeholders. The keys that were applied before and are no
+// longer in any layer are cleared, since merging alone would keep their stale values.
+func (sl *settingsLayers) apply() error {
+
+	merged := copyProperties(sl.local)
+	mergeProperties(merged, copyProperties(sl.remote))
+	resolvePlaceholders(merged, sl.flags)
+
+	current := flattenProperties(merged)
+
+	for key := range sl.applied {
+		if _, ok := current[key]; !ok {
+			setPath(merged, parsePath(key), nil)
+		}
 	}
 
-	if cloudConfig.Name != "" {
-		log.Printf("successfully loaded configuration for service %s\n", cloudConfig.Name)
+	err := viper.MergeConfigMap(merged)
+	if err != nil {
+		return err
 	}
 
+	sl.applied = current
+
+	return nil
+
 }
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ? =========================== Constants =========================== ?
+
+// maxPlaceholderDepth stops the resolution of placeholders that refer to each other
+const maxPlaceholderDepth = 10
+
+// ? =========================== Variables =========================== ?
+
+// envKeyReplacer turns a key into its environment variable with the relaxed binding of Spring: database.host and
+// pool.max-size are read from DATABASE_HOST and POOL_MAX_SIZE
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// placeholderPattern matches ${key} and ${key:default}, the default may be empty
+var placeholderPattern = regexp.MustCompile(`\$\{([^}:]+)(?::([^}]*))?}`)
+
+// ? =========================== Functions =========================== ?
+
+// LoadCommandLine takes the --key=value and --key value arguments as the configuration with the highest precedence, a
+// flag without a value is true. The other arguments are ignored. The flags of a previous call are replaced.
+func LoadCommandLine(args []string) error {
+
+	layers.mu.Lock()
+	defer layers.mu.Unlock()
+
+	layers.flags = map[string]string{}
+
+	for i := 0; i < len(args); i++ {
+
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") || arg == "--" {
+			continue
+		}
+
+		key, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if key == "" {
+			return fmt.Errorf("invalid command line argument %q", arg)
+		}
+
+		if !hasValue {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+				i++
+				value = args[i]
+			} else {
+				value = "true"
+			}
+		}
+
+		key = strings.ToLower(key)
+		layers.flags[key] = value
+		viper.Set(key, value)
+
+	}
+
+	return nil
+
+}
+
+// * ============
+
+// ActiveProfiles returns the profiles in application.config.profile, which may list several separated by commas. The
+// profiles listed last have the highest precedence.
+func ActiveProfiles() []string {
+
+	var profiles []string
+
+	for _, profile := range strings.Split(viper.GetString("application.config.profile"), ",") {
+		if profile = strings.TrimSpace(profile); profile != "" {
+			profiles = append(profiles, profile)
+		}
+	}
+
+	return profiles
+
+}
+
+// * ============
+
+// bindEnvironment reads every key from its environment variable when it is set, above the configuration files
+func bindEnvironment() {
+	viper.SetEnvKeyReplacer(envKeyReplacer)
+	viper.AutomaticEnv()
+}
+
+// * ============
+
+// envVariable returns the environment variable of the key with relaxed binding
+func envVariable(key string) string {
+	return strings.ToUpper(envKeyReplacer.Replace(key))
+}
+
+// * ============
+
+// resolvePlaceholders replaces the ${key:default} placeholders of the string values with the value of the key in the
+// command line, the environment or the configuration, in that order. The placeholders of unknown keys without default
+// are kept as they are.
+func resolvePlaceholders(properties map[string]interface{}, flags map[string]string) {
+
+	flat := flattenProperties(properties)
+
+	var lookup func(key string, depth int) (string, bool)
+	lookup = func(key string, depth int) (string, bool) {
+
+		if value, ok := flags[strings.ToLower(key)]; ok {
+			return value, true
+		}
+
+		if value, ok := os.LookupEnv(key); ok {
+			return value, true
+		}
+
+		if value, ok := os.LookupEnv(envVariable(key)); ok {
+			return value, true
+		}
+
+		value, ok := flat[strings.ToLower(key)]
+		if !ok || value == nil {
+			return "", false
+		}
+
+		if text, isText := value.(string); isText {
+			return resolveText(text, lookup, depth+1), true
+		}
+
+		return fmt.Sprint(value), true
+
+	}
+
+	var resolve func(value interface{}) interface{}
+	resolve = func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case string:
+			return resolveText(v, lookup, 0)
+		case map[string]interface{}:
+			for key, nested := range v {
+				v[key] = resolve(nested)
+			}
+		case []interface{}:
+			for i, item := range v {
+				v[i] = resolve(item)
+			}
+		}
+		return value
+	}
+
+	resolve(properties)
+
+}
+
+// * ============
+
+// resolveText replaces the placeholders of the text
+func resolveText(text string, lookup func(key string, depth int) (string, bool), depth int) string {
+
+	if depth > maxPlaceholderDepth || !strings.Contains(text, "${") {
+		return text
+	}
+
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+
+		match := placeholderPattern.FindStringSubmatch(placeholder)
+
+		if value, ok := lookup(strings.TrimSpace(match[1]), depth); ok {
+			return value
+		}
+
+		if strings.Contains(placeholder, ":") {
+			return match[2]
+		}
+
+		return placeholder
+
+	})
+
+}
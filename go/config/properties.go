@@ -0,0 +1,215 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ? =========================== Functions =========================== ?
+
+// mergePropertySources merges all the property sources into a single nested map. Spring lists the sources from the
+// highest to the lowest precedence, so they are merged from the last to the first and the first one wins.
+func mergePropertySources(sources []propertySource) map[string]interface{} {
+
+	merged := map[string]interface{}{}
+
+	for i := len(sources) - 1; i >= 0; i-- {
+		mergeProperties(merged, expandProperties(sources[i].Source))
+	}
+
+	return merged
+
+}
+
+// * ============
+
+// expandProperties turns flat keys such as a.b[0].c into nested maps and slices
+func expandProperties(properties map[string]interface{}) map[string]interface{} {
+
+	expanded := map[string]interface{}{}
+
+	for key, value := range properties {
+
+		path := parsePath(key)
+
+		if len(path) > 0 {
+			if _, ok := path[0].(string); ok {
+				setPath(expanded, path, value)
+				continue
+			}
+		}
+
+		// Keys that do not start with a name cannot be placed in the map, so they are kept as they are
+		expanded[key] = value
+
+	}
+
+	return expanded
+
+}
+
+// * ============
+
+// mergeProperties merges the source into the destination, the source wins. Nested maps are merged key by key but
+// lists are replaced as a whole, the same way Spring binds lists from several property sources.
+func mergeProperties(destination map[string]interface{}, source map[string]interface{}) {
+
+	for key, value := range source {
+
+		sourceMap, sourceIsMap := value.(map[string]interface{})
+		destinationMap, destinationIsMap := destination[key].(map[string]interface{})
+
+		if sourceIsMap && destinationIsMap {
+			mergeProperties(destinationMap, sourceMap)
+			continue
+		}
+
+		destination[key] = value
+
+	}
+
+}
+
+// * ============
+
+// parsePath splits a property key into its map keys (strings) and list indexes (ints)
+func parsePath(key string) []interface{} {
+
+	var path []interface{}
+
+	for _, segment := range strings.Split(key, ".") {
+
+		name := segment
+		var indexes []interface{}
+
+		// Trailing [n] suffixes are list indexes, anything that does not parse is kept as part of the name
+		for strings.HasSuffix(name, "]") {
+
+			open := strings.LastIndex(name, "[")
+			if open < 0 {
+				break
+			}
+
+			index, err := strconv.Atoi(name[open+1 : len(name)-1])
+			if err != nil || index < 0 {
+				break
+			}
+
+			indexes = append([]interface{}{index}, indexes...)
+			name = name[:open]
+
+		}
+
+		if name != "" {
+			path = append(path, name)
+		}
+		path = append(path, indexes...)
+
+	}
+
+	return path
+
+}
+
+// * ============
+
+// setPath sets the value at the path inside the container, creating the maps and growing the lists it needs
+func setPath(container interface{}, path []interface{}, value interface{}) interface{} {
+
+	if len(path) == 0 {
+		return value
+	}
+
+	switch token := path[0].(type) {
+
+	case int:
+
+		list, _ := container.([]interface{})
+		for len(list) <= token {
+			list = append(list, nil)
+		}
+		list[token] = setPath(list[token], path[1:], value)
+
+		return list
+
+	default:
+
+		name := token.(string)
+		properties, ok := container.(map[string]interface{})
+		if !ok {
+			properties = map[string]interface{}{}
+		}
+		properties[name] = setPath(properties[name], path[1:], value)
+
+		return properties
+
+	}
+
+}
+
+// * ============
+
+// copyProperties returns a deep copy of the nested maps and lists, so changing the copy leaves the original untouched
+func copyProperties(properties map[string]interface{}) map[string]interface{} {
+
+	copied := make(map[string]interface{}, len(properties))
+
+	for key, value := range properties {
+		copied[key] = copyValue(value)
+	}
+
+	return copied
+
+}
+
+// * ============
+
+// copyValue returns a deep copy of the value when it is a map or a list
+func copyValue(value interface{}) interface{} {
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copyProperties(v)
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	default:
+		return value
+	}
+
+}
+
+// * ============
+
+// flattenProperties returns the leaves of the nested maps by their dotted lower case keys, lists are kept as leaves
+func flattenProperties(properties map[string]interface{}) map[string]interface{} {
+
+	flat := map[string]interface{}{}
+
+	var walk func(prefix string, properties map[string]interface{})
+	walk = func(prefix string, properties map[string]interface{}) {
+		for key, value := range properties {
+
+			fullKey := strings.ToLower(key)
+			if prefix != "" {
+				fullKey = prefix + "." + fullKey
+			}
+
+			if nested, ok := value.(map[string]interface{}); ok {
+				walk(fullKey, nested)
+				continue
+			}
+
+			flat[fullKey] = value
+
+		}
+	}
+
+	walk("", properties)
+
+	return flat
+
+}
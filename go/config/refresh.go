@@ -0,0 +1,216 @@
+package config
+
+import (
+	"MicroserviceTemplate/pkg/metrics"
+	"github.com/spf13/viper"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ? =========================== Interfaces =========================== ?
+
+type IRefresher interface {
+	Refresh() ([]Change, error)
+	Subscribe(prefix string, listener Listener)
+	StartPolling(interval time.Duration)
+	StopPolling()
+}
+
+// ? =========================== Types =========================== ?
+
+// Listener receives the changes of the keys it subscribed to after a refresh
+type Listener func(changes []Change)
+
+// ? =========================== Structs =========================== ?
+
+// Change is a key whose value is different after a refresh, a nil value means the key is not set
+type Change struct {
+	Key      string      `json:"key"`
+	OldValue interface{} `json:"oldValue"`
+	NewValue interface{} `json:"newValue"`
+}
+
+// * ============
+
+// subscription is a listener of the keys that start with the prefix
+type subscription struct {
+	prefix   string
+	listener Listener
+}
+
+// * ============
+
+// Refresher reloads the configuration. refreshMu serialises the refreshes, which can take the whole retry budget of the
+// config client, while mu only guards the subscriptions and the polling so they never wait for a refresh.
+type Refresher struct {
+	refreshMu     sync.Mutex
+	mu            sync.Mutex
+	subscriptions []subscription
+	stop          chan struct{}
+}
+
+// ? =========================== Constructors =========================== ?
+
+// NewRefresher returns a refresher of the configuration loaded from the config server
+func NewRefresher() IRefresher {
+	return &Refresher{}
+}
+
+// ? =========================== Methods =========================== ?
+
+// Refresh fetches the configuration from the config server again and publishes the changed keys to the listeners
+func (r *Refresher) Refresh() ([]Change, error) {
+
+	r.refreshMu.Lock()
+
+	before := flattenProperties(viper.AllSettings())
+
+	err := loadRemoteConfiguration()
+	metrics.RecordConfigRefresh(err)
+
+	if err != nil {
+		r.refreshMu.Unlock()
+		return nil, err
+	}
+
+	changes := diffProperties(before, flattenProperties(viper.AllSettings()))
+
+	r.refreshMu.Unlock()
+
+	r.mu.Lock()
+	subscriptions := append([]subscription(nil), r.subscriptions...)
+	r.mu.Unlock()
+
+	for _, change := range changes {
+		log.Printf("configuration key %s changed\n", change.Key)
+	}
+
+	// The listeners are called outside the locks, so they can read the configuration or subscribe again
+	for _, s := range subscriptions {
+
+		var matched []Change
+		for _, change := range changes {
+			if matchesPrefix(change.Key, s.prefix) {
+				matched = append(matched, change)
+			}
+		}
+
+		if len(matched) > 0 {
+			s.listener(matched)
+		}
+
+	}
+
+	return changes, nil
+
+}
+
+// * ============
+
+// Subscribe registers a listener of the keys that start with the prefix, an empty prefix listens to every key
+func (r *Refresher) Subscribe(prefix string, listener Listener) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscriptions = append(r.subscriptions, subscription{strings.ToLower(prefix), listener})
+
+}
+
+// * ============
+
+// StartPolling refreshes the configuration every interval until StopPolling is called
+func (r *Refresher) StartPolling(interval time.Duration) {
+
+	if interval <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop != nil {
+		return
+	}
+
+	r.stop = make(chan struct{})
+
+	go func(stop <-chan struct{}) {
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := r.Refresh(); err != nil {
+					log.Printf("couldn't refresh the configuration, keeping the current one. error: %s\n", err.Error())
+				}
+			}
+		}
+
+	}(r.stop)
+
+	log.Printf("polling the config server every %s\n", interval)
+
+}
+
+// * ============
+
+// StopPolling stops the polling. It does not wait for a refresh in progress, which can retry an unreachable config
+// server for the whole retry budget, so it never holds up the shutdown.
+func (r *Refresher) StopPolling() {
+
+	r.mu.Lock()
+	stop := r.stop
+	r.stop = nil
+	r.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+
+}
+
+// ? =========================== Functions =========================== ?
+
+// diffProperties returns the keys whose values are different, sorted by key
+func diffProperties(before map[string]interface{}, after map[string]interface{}) []Change {
+
+	var changes []Change
+
+	for key, oldValue := range before {
+		newValue := after[key]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, Change{key, oldValue, newValue})
+		}
+	}
+
+	for key, newValue := range after {
+		if _, ok := before[key]; !ok && newValue != nil {
+			changes = append(changes, Change{key, nil, newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+
+}
+
+// * ============
+
+// matchesPrefix reports whether the key is the prefix or is nested under it
+func matchesPrefix(key string, prefix string) bool {
+	return prefix == "" || key == prefix || strings.HasPrefix(key, prefix+".")
+}
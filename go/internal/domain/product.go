@@ -4,9 +4,10 @@ package domain
 
 type Product struct {
 	ID       string  `bson:"_id" json:"_id"`
-	Name     string  `bson:"name" json:"name"`
-	Quantity int     `bson:"quantity" json:"quantity"`
-	Price    float64 `bson:"price" json:"price"`
+	Name     string  `bson:"name" json:"name" validate:"required,min=2,max=120"`
+	Quantity int     `bson:"quantity" json:"quantity" validate:"gte=0"`
+	Price    float64 `bson:"price" json:"price" validate:"gt=0,currency"`
+	Version  int64   `bson:"version" json:"version"`
 }
 
 // * =========== *
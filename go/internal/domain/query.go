@@ -0,0 +1,119 @@
+package domain
+
+// ? =================== Constants =================== ?
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	// MaxPage bounds the documents MongoDB skips to reach a numbered page, the continuation tokens go further
+	MaxPage = 1000
+
+	SortAscending  = "asc"
+	SortDescending = "desc"
+)
+
+// ? =================== Variables =================== ?
+
+// ProductSortFields maps the fields that products can be sorted by to their document keys
+var ProductSortFields = map[string]string{
+	"id":       "_id",
+	"name":     "name",
+	"price":    "price",
+	"quantity": "quantity",
+}
+
+// ? =================== Structs =================== ?
+
+// ProductQuery contains the criteria used to list products
+type ProductQuery struct {
+	Page        int
+	Size        int
+	SortField   string
+	SortOrder   string
+	NamePrefix  string
+	MinPrice    *float64
+	MaxPrice    *float64
+	MinQuantity *int
+	MaxQuantity *int
+	After       *ProductCursor
+}
+
+// * =========== *
+
+// ProductPage is a page of products along with the information needed to navigate through the rest of them
+type ProductPage struct {
+	Items      Products  `json:"items"`
+	Page       int       `json:"page"`
+	Size       int       `json:"size"`
+	Total      int64     `json:"total"`
+	TotalPages int       `json:"totalPages"`
+	NextToken  string    `json:"nextToken,omitempty"`
+	Links      PageLinks `json:"_links"`
+}
+
+// * =========== *
+
+// ProductCursorPage is a slice of products read after a continuation token
+type ProductCursorPage struct {
+	Items     Products  `json:"items"`
+	Size      int       `json:"size"`
+	NextToken string    `json:"nextToken,omitempty"`
+	Links     PageLinks `json:"_links"`
+}
+
+// * =========== *
+
+// ProductCursor is the position of the last product read, it is the content of a continuation token
+type ProductCursor struct {
+	SortField string      `json:"f"`
+	SortOrder string      `json:"o"`
+	SortValue interface{} `json:"v,omitempty"`
+	LastID    string      `json:"id"`
+}
+
+// * =========== *
+
+// PageLinks contains the links to navigate between pages
+type PageLinks struct {
+	Self  string `json:"self"`
+	First string `json:"first,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last,omitempty"`
+}
+
+// ? =================== Constructors =================== ?
+
+// NewProductQuery returns a query for the first page sorted by ID
+func NewProductQuery() *ProductQuery {
+	return &ProductQuery{
+		Page:      0,
+		Size:      DefaultPageSize,
+		SortField: "id",
+		SortOrder: SortAscending,
+	}
+}
+
+// * =========== *
+
+// NewProductCursor returns the cursor that points right after the product for the given sort
+func NewProductCursor(product *Product, sortField string, sortOrder string) *ProductCursor {
+
+	cursor := &ProductCursor{
+		SortField: sortField,
+		SortOrder: sortOrder,
+		LastID:    product.ID,
+	}
+
+	switch sortField {
+	case "name":
+		cursor.SortValue = product.Name
+	case "price":
+		cursor.SortValue = product.Price
+	case "quantity":
+		cursor.SortValue = product.Quantity
+	}
+
+	return cursor
+
+}
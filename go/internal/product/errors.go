@@ -0,0 +1,157 @@
+package product
+
+import (
+	"MicroserviceTemplate/pkg/web"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"net/http"
+)
+
+// ? ==================== Constants ==================== ?
+
+// Kind classifies the errors of the product package by their cause
+type Kind string
+
+const (
+	KindNotFound           Kind = "not_found"
+	KindConflict           Kind = "conflict"
+	KindValidation         Kind = "validation"
+	KindUnavailable        Kind = "unavailable"
+	KindPreconditionFailed Kind = "precondition_failed"
+	KindUnsupported        Kind = "unsupported_media_type"
+	KindInternal           Kind = "internal"
+)
+
+// ? ==================== Variables ==================== ?
+
+// statusByKind maps every kind of error to the HTTP status code it is reported with
+var statusByKind = map[Kind]int{
+	KindNotFound:           http.StatusNotFound,
+	KindConflict:           http.StatusConflict,
+	KindValidation:         http.StatusBadRequest,
+	KindUnavailable:        http.StatusServiceUnavailable,
+	KindPreconditionFailed: http.StatusPreconditionFailed,
+	KindUnsupported:        http.StatusUnsupportedMediaType,
+	KindInternal:           http.StatusInternalServerError,
+}
+
+// ? ==================== Errors ==================== ?
+
+var (
+	// ErrNotFound is returned when the product does not exist
+	ErrNotFound = NewError(KindNotFound, "product not found", nil)
+	// ErrUnavailable is returned when the database cannot be reached or does not answer in time
+	ErrUnavailable = NewError(KindUnavailable, "the product database is unavailable", nil)
+	// ErrVersionMismatch is returned when the stored product no longer has the version the writer expected
+	ErrVersionMismatch = NewError(KindPreconditionFailed, "the product was modified by another request", nil)
+	// ErrUnsupportedPatch is returned when the patch media type is not supported
+	ErrUnsupportedPatch = NewError(KindUnsupported, "unsupported patch media type", nil)
+	// ErrInvalidPatch is returned when the patch is malformed or cannot be applied to the product
+	ErrInvalidPatch = NewError(KindValidation, "invalid patch", nil)
+	// ErrPatchTestFailed is returned when a test operation of a JSON Patch does not hold
+	ErrPatchTestFailed = NewError(KindConflict, "patch test operation failed", nil)
+	// ErrInvalidToken is returned when the continuation token cannot be used
+	ErrInvalidToken = NewError(KindValidation, "invalid continuation token", nil)
+)
+
+// ? ==================== Structs ==================== ?
+
+// Error is an error of the product package, it keeps the driver error that caused it
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+	Fields  []web.FieldError
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewError returns a new product error of the given kind
+func NewError(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// ? ==================== Methods ==================== ?
+
+// Error returns the message of the error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// * =========== *
+
+// Unwrap returns the error that caused this one
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// * =========== *
+
+// Is reports whether the target is a sentinel error with the same kind and message, so errors.Is(err, ErrNotFound) holds for every product not found
+func (e *Error) Is(target error) bool {
+
+	sentinel, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+
+	return sentinel.Err == nil && sentinel.Kind == e.Kind && sentinel.Message == e.Message
+
+}
+
+// * =========== *
+
+// StatusCode returns the HTTP status code the error is reported with
+func (e *Error) StatusCode() int {
+
+	status, ok := statusByKind[e.Kind]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+
+	return status
+
+}
+
+// * =========== *
+
+// FieldErrors returns the fields of the request that broke a validation rule
+func (e *Error) FieldErrors() []web.FieldError {
+	return e.Fields
+}
+
+// * =========== *
+
+// ErrorCode returns the machine-readable code of the error
+func (e *Error) ErrorCode() string {
+	return string(e.Kind)
+}
+
+// ? ==================== Functions ==================== ?
+
+// translateError turns the errors returned by the MongoDB driver into product errors
+func translateError(err error) error {
+
+	var productError *Error
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &productError):
+		return err
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return NewError(KindNotFound, ErrNotFound.Message, err)
+	case mongo.IsDuplicateKeyError(err):
+		return NewError(KindConflict, "a product with the same key already exists", err)
+	case errors.Is(err, context.Canceled):
+		return NewError(KindUnavailable, "the request was canceled before the products could be accessed", err)
+	case mongo.IsTimeout(err), mongo.IsNetworkError(err), errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, mongo.ErrClientDisconnected), errors.As(err, &topology.ServerSelectionError{}):
+		return NewError(KindUnavailable, ErrUnavailable.Message, err)
+	default:
+		return NewError(KindInternal, "unexpected error accessing the products", err)
+	}
+
+}
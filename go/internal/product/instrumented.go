@@ -0,0 +1,107 @@
+package product
+
+import (
+	"MicroserviceTemplate/internal/domain"
+	"MicroserviceTemplate/pkg/metrics"
+	"context"
+	"errors"
+	"time"
+)
+
+// ? ==================== Structs ======================== ?
+
+// InstrumentedRepository records the latency and errors of every method of the repository it wraps
+type InstrumentedRepository struct {
+	repository IRepository
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewInstrumentedRepository returns a repository that records metrics of the given one
+func NewInstrumentedRepository(repository IRepository) IRepository {
+	return &InstrumentedRepository{repository}
+}
+
+// ? ==================== Methods ====================== ?
+
+// GetAll returns the page of products that match the query along with the total number of matches
+func (ir *InstrumentedRepository) GetAll(ctx context.Context, query *domain.ProductQuery) (*domain.Products, int64, error) {
+	start := time.Now()
+	products, total, err := ir.repository.GetAll(ctx, query)
+	observe("GetAll", start, err)
+	return products, total, err
+}
+
+// * =========== *
+
+// GetAfter returns the products that match the query and come right after its cursor
+func (ir *InstrumentedRepository) GetAfter(ctx context.Context, query *domain.ProductQuery) (*domain.Products, error) {
+	start := time.Now()
+	products, err := ir.repository.GetAfter(ctx, query)
+	observe("GetAfter", start, err)
+	return products, err
+}
+
+// * =========== *
+
+// GetByID returns a product by its ID
+func (ir *InstrumentedRepository) GetByID(ctx context.Context, id string) (*domain.Product, error) {
+	start := time.Now()
+	product, err := ir.repository.GetByID(ctx, id)
+	observe("GetByID", start, err)
+	return product, err
+}
+
+// * =========== *
+
+// Save saves a product
+func (ir *InstrumentedRepository) Save(ctx context.Context, product *domain.Product) (domain.Product, error) {
+	start := time.Now()
+	productSaved, err := ir.repository.Save(ctx, product)
+	observe("Save", start, err)
+	return productSaved, err
+}
+
+// * =========== *
+
+// Update update a product if it still has the expected version
+func (ir *InstrumentedRepository) Update(ctx context.Context, product *domain.Product, expectedVersion int64) (*domain.Product, error) {
+	start := time.Now()
+	productUpdated, err := ir.repository.Update(ctx, product, expectedVersion)
+	observe("Update", start, err)
+	return productUpdated, err
+}
+
+// * =========== *
+
+// Delete eliminates a product if it still has the expected version
+func (ir *InstrumentedRepository) Delete(ctx context.Context, id string, expectedVersion int64) error {
+	start := time.Now()
+	err := ir.repository.Delete(ctx, id, expectedVersion)
+	observe("Delete", start, err)
+	return err
+}
+
+// ? ==================== Functions ====================== ?
+
+// observe records the operation labelling its error by kind. Only the failures of MongoDB are counted as errors, a
+// product not found, a duplicate key or a version that no longer matches are answers of the database to the request.
+func observe(method string, start time.Time, err error) {
+
+	errorKind := ""
+
+	if err != nil {
+		errorKind = string(KindInternal)
+		var productError *Error
+		if errors.As(err, &productError) {
+			errorKind = string(productError.Kind)
+		}
+	}
+
+	if errorKind != string(KindInternal) && errorKind != string(KindUnavailable) {
+		errorKind = ""
+	}
+
+	metrics.ObserveMongo(method, start, errorKind)
+
+}
@@ -0,0 +1,63 @@
+package product
+
+import (
+	"MicroserviceTemplate/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// ? ==================== Constants ==================== ?
+
+const (
+	// MergePatch is the media type of a JSON Merge Patch document (RFC 7396)
+	MergePatch = "application/merge-patch+json"
+	// JSONPatch is the media type of a JSON Patch document (RFC 6902)
+	JSONPatch = "application/json-patch+json"
+)
+
+// ? ==================== Functions ==================== ?
+
+// applyPatch applies the patch document to the product and returns the patched copy
+func applyPatch(product *domain.Product, patch []byte, patchType string) (*domain.Product, error) {
+
+	document, err := json.Marshal(product)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+
+	switch patchType {
+	case MergePatch:
+		patched, err = jsonpatch.MergePatch(document, patch)
+	case JSONPatch:
+		var operations jsonpatch.Patch
+		operations, err = jsonpatch.DecodePatch(patch)
+		if err == nil {
+			patched, err = operations.Apply(document)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedPatch, patchType)
+	}
+
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return nil, fmt.Errorf("%w: %s", ErrPatchTestFailed, err.Error())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err.Error())
+	}
+
+	var productPatched domain.Product
+	if err := json.Unmarshal(patched, &productPatched); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err.Error())
+	}
+
+	// The identity and version of the product are owned by the server
+	productPatched.ID = product.ID
+	productPatched.Version = product.Version
+
+	return &productPatched, nil
+
+}
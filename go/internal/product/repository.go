@@ -4,28 +4,41 @@ import (
 	"MicroserviceTemplate/internal/domain"
 	store "MicroserviceTemplate/pkg/store/product"
 	"context"
+	"errors"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"regexp"
+	"time"
 )
 
+// ? ==================== Constants ==================== ?
+
+// AnyVersion is the expected version that skips the concurrency check on writes
+const AnyVersion int64 = -1
+
+// defaultOperationTimeout bounds the database operations when not even the default timeout is configured
+const defaultOperationTimeout = 5 * time.Second
+
 // ? ==================== Interfaces ==================== ?
 
 type IRepository interface {
-	GetAll() (*domain.Products, error)
-	GetByID(id string) (*domain.Product, error)
-	Save(product *domain.Product) (domain.Product, error)
-	Update(product *domain.Product) error
-	PatchUpdate(product *domain.Product) error
-	Delete(id string) error
+	GetAll(ctx context.Context, query *domain.ProductQuery) (*domain.Products, int64, error)
+	GetAfter(ctx context.Context, query *domain.ProductQuery) (*domain.Products, error)
+	GetByID(ctx context.Context, id string) (*domain.Product, error)
+	Save(ctx context.Context, product *domain.Product) (domain.Product, error)
+	Update(ctx context.Context, product *domain.Product, expectedVersion int64) (*domain.Product, error)
+	Delete(ctx context.Context, id string, expectedVersion int64) error
 }
 
 // ? ==================== Structs ======================== ?
 
 type Repository struct {
-	db  *mongo.Collection
-	ctx context.Context
+	db    *mongo.Collection
+	store store.IProductStore
 }
 
 // ? ==================== Constructors ==================== ?
@@ -38,55 +51,71 @@ func NewRepository(store store.IProductStore) IRepository {
 		log.Fatal(err)
 	}
 
-	return &Repository{db, context.Background()}
+	return NewInstrumentedRepository(&Repository{db, store})
 }
 
 // ? ==================== Methods ====================== ?
 
-// GetAll returns all products
-func (r *Repository) GetAll() (*domain.Products, error) {
+// GetAll returns the page of products that match the query along with the total number of matches
+func (r *Repository) GetAll(ctx context.Context, query *domain.ProductQuery) (*domain.Products, int64, error) {
+
+	filter := buildFilter(query)
 
-	var products domain.Products
-	filter := bson.D{}
+	countCtx, cancel := r.withTimeout(ctx, "count")
+	defer cancel()
 
-	cur, err := r.db.Find(r.ctx, filter)
+	total, err := r.db.CountDocuments(countCtx, filter)
 	if err != nil {
-		return nil, err
+		return nil, 0, translateError(err)
 	}
 
-	for cur.Next(r.ctx) {
-		var product domain.Product
-		err := cur.Decode(&product)
-		if err != nil {
-			return nil, err
-		}
-		products = append(products, &product)
-	}
+	findOptions := options.Find().
+		SetSort(buildSort(query)).
+		SetSkip(int64(query.Page) * int64(query.Size)).
+		SetLimit(int64(query.Size))
 
-	if err := cur.Err(); err != nil {
-		return nil, err
+	products, err := r.find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	err = cur.Close(r.ctx)
-	if err != nil {
-		return nil, err
+	return products, total, nil
+
+}
+
+// * =========== *
+
+// GetAfter returns the products that match the query and come right after its cursor, without skipping documents
+func (r *Repository) GetAfter(ctx context.Context, query *domain.ProductQuery) (*domain.Products, error) {
+
+	filter := buildFilter(query)
+
+	if query.After != nil {
+		filter = bson.M{"$and": bson.A{filter, buildKeysetFilter(query.After)}}
 	}
 
-	return &products, nil
+	findOptions := options.Find().
+		SetSort(buildSort(query)).
+		SetLimit(int64(query.Size))
+
+	return r.find(ctx, filter, findOptions)
 
 }
 
 // * =========== *
 
 // GetByID returns a product by its ID
-func (r *Repository) GetByID(id string) (*domain.Product, error) {
+func (r *Repository) GetByID(ctx context.Context, id string) (*domain.Product, error) {
+
+	ctx, cancel := r.withTimeout(ctx, "find-one")
+	defer cancel()
 
 	var product domain.Product
 	filter := bson.D{{Key: "_id", Value: id}}
 
-	err := r.db.FindOne(r.ctx, filter).Decode(&product)
+	err := r.db.FindOne(ctx, filter).Decode(&product)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	return &product, nil
@@ -96,13 +125,17 @@ func (r *Repository) GetByID(id string) (*domain.Product, error) {
 // * =========== *
 
 // Save saves a product
-func (r *Repository) Save(product *domain.Product) (domain.Product, error) {
+func (r *Repository) Save(ctx context.Context, product *domain.Product) (domain.Product, error) {
+
+	ctx, cancel := r.withTimeout(ctx, "insert")
+	defer cancel()
 
 	product.ID = uuid.New().String()
+	product.Version = 1
 
-	_, err := r.db.InsertOne(r.ctx, product)
+	_, err := r.db.InsertOne(ctx, product)
 	if err != nil {
-		return domain.Product{}, err
+		return domain.Product{}, translateError(err)
 	}
 
 	return *product, nil
@@ -111,26 +144,56 @@ func (r *Repository) Save(product *domain.Product) (domain.Product, error) {
 
 // * =========== *
 
-// Update update a product
-func (r *Repository) Update(product *domain.Product) error {
+// Update update a product if it still has the expected version, incrementing its version
+func (r *Repository) Update(ctx context.Context, product *domain.Product, expectedVersion int64) (*domain.Product, error) {
 
-	productToUpdate, err := r.GetByID(product.ID)
-	if err != nil {
-		return err
-	}
+	updateCtx, cancel := r.withTimeout(ctx, "update")
+	defer cancel()
 
-	filter := bson.D{{"_id", productToUpdate.ID}}
+	filter := versionFilter(product.ID, expectedVersion)
 
 	update := bson.M{
 		"$set": bson.M{
 			"name":     product.Name,
 			"quantity": product.Quantity,
+			"price":    product.Price,
+		},
+		"$inc": bson.M{
+			"version": 1,
 		},
 	}
 
-	_, err = r.db.UpdateOne(r.ctx, filter, update)
+	var productUpdated domain.Product
+
+	updateOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := r.db.FindOneAndUpdate(updateCtx, filter, update, updateOptions).Decode(&productUpdated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, r.writeConflict(ctx, product.ID)
+	}
 	if err != nil {
-		return err
+		return nil, translateError(err)
+	}
+
+	return &productUpdated, nil
+
+}
+
+// * =========== *
+
+// Delete eliminates a product if it still has the expected version
+func (r *Repository) Delete(ctx context.Context, id string, expectedVersion int64) error {
+
+	deleteCtx, cancel := r.withTimeout(ctx, "delete")
+	defer cancel()
+
+	result, err := r.db.DeleteOne(deleteCtx, versionFilter(id, expectedVersion))
+	if err != nil {
+		return translateError(err)
+	}
+
+	if result.DeletedCount == 0 {
+		return r.writeConflict(ctx, id)
 	}
 
 	return nil
@@ -139,47 +202,186 @@ func (r *Repository) Update(product *domain.Product) error {
 
 // * =========== *
 
-// PatchUpdate update a product partially with the fields that are sent to you
-func (r *Repository) PatchUpdate(product *domain.Product) error {
+// writeConflict tells apart a write that matched nothing because the product does not exist from one that found another version
+func (r *Repository) writeConflict(ctx context.Context, id string) error {
 
-	productToUpdate, err := r.GetByID(product.ID)
+	_, err := r.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if product.Name != "" {
-		productToUpdate.Name = product.Name
-	}
+	return ErrVersionMismatch
+
+}
+
+// * =========== *
 
-	if product.Price != 0 {
-		productToUpdate.Price = product.Price
+// withTimeout bounds the operation by its timeout in database.timeouts, or by the default one when it has none. The
+// timeouts are read on every operation, so a refresh of the configuration applies from the next one.
+func (r *Repository) withTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+
+	timeouts := r.store.Timeouts()
+
+	var timeout time.Duration
+
+	switch operation {
+	case "count":
+		timeout = timeouts.Count
+	case "find":
+		timeout = timeouts.Find
+	case "find-one":
+		timeout = timeouts.FindOne
+	case "insert":
+		timeout = timeouts.Insert
+	case "update":
+		timeout = timeouts.Update
+	case "delete":
+		timeout = timeouts.Delete
 	}
 
-	if product.Quantity != 0 {
-		productToUpdate.Quantity = product.Quantity
+	if timeout <= 0 {
+		timeout = timeouts.Default
+	}
+	if timeout <= 0 {
+		timeout = defaultOperationTimeout
 	}
 
-	return r.Update(productToUpdate)
+	return context.WithTimeout(ctx, timeout)
 
 }
 
 // * =========== *
 
-// Delete eliminates a product
-func (r *Repository) Delete(id string) error {
+// find runs the query and decodes every product in the cursor
+func (r *Repository) find(ctx context.Context, filter interface{}, findOptions *options.FindOptions) (*domain.Products, error) {
+
+	ctx, cancel := r.withTimeout(ctx, "find")
+	defer cancel()
 
-	productToDelete, err := r.GetByID(id)
+	products := domain.Products{}
+
+	cur, err := r.db.Find(ctx, filter, findOptions)
 	if err != nil {
-		return err
+		return nil, translateError(err)
 	}
 
-	filter := bson.M{"_id": productToDelete.ID}
+	for cur.Next(ctx) {
+		var product domain.Product
+		err := cur.Decode(&product)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		products = append(products, &product)
+	}
 
-	_, err = r.db.DeleteOne(r.ctx, filter)
+	if err := cur.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	err = cur.Close(ctx)
 	if err != nil {
-		return err
+		return nil, translateError(err)
 	}
 
-	return nil
+	return &products, nil
+
+}
+
+// ? ==================== Functions ====================== ?
+
+// versionFilter matches the product with the ID, as long as it has the expected version
+func versionFilter(id string, expectedVersion int64) bson.M {
+
+	filter := bson.M{"_id": id}
+
+	switch expectedVersion {
+	case AnyVersion:
+	case 0:
+		// Products stored before versioning was introduced have no version field
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	default:
+		filter["version"] = expectedVersion
+	}
+
+	return filter
+
+}
+
+// * =========== *
+
+// buildSort returns the sort of the query, using the ID as tiebreaker so the order is always stable
+func buildSort(query *domain.ProductQuery) bson.D {
+
+	order := 1
+	if query.SortOrder == domain.SortDescending {
+		order = -1
+	}
+
+	sort := bson.D{{Key: domain.ProductSortFields[query.SortField], Value: order}}
+	if query.SortField != "id" {
+		sort = append(sort, bson.E{Key: "_id", Value: order})
+	}
+
+	return sort
+
+}
+
+// * =========== *
+
+// buildKeysetFilter returns the filter that matches the documents placed after the cursor in the sort order
+func buildKeysetFilter(cursor *domain.ProductCursor) bson.M {
+
+	operator := "$gt"
+	if cursor.SortOrder == domain.SortDescending {
+		operator = "$lt"
+	}
+
+	if cursor.SortField == "id" {
+		return bson.M{"_id": bson.M{operator: cursor.LastID}}
+	}
+
+	field := domain.ProductSortFields[cursor.SortField]
+
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{operator: cursor.SortValue}},
+		bson.M{field: cursor.SortValue, "_id": bson.M{operator: cursor.LastID}},
+	}}
+
+}
+
+// * =========== *
+
+// buildFilter translates the query criteria into a MongoDB filter
+func buildFilter(query *domain.ProductQuery) bson.M {
+
+	filter := bson.M{}
+
+	if query.NamePrefix != "" {
+		filter["name"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(query.NamePrefix), Options: "i"}
+	}
+
+	price := bson.M{}
+	if query.MinPrice != nil {
+		price["$gte"] = *query.MinPrice
+	}
+	if query.MaxPrice != nil {
+		price["$lte"] = *query.MaxPrice
+	}
+	if len(price) > 0 {
+		filter["price"] = price
+	}
+
+	quantity := bson.M{}
+	if query.MinQuantity != nil {
+		quantity["$gte"] = *query.MinQuantity
+	}
+	if query.MaxQuantity != nil {
+		quantity["$lte"] = *query.MaxQuantity
+	}
+	if len(quantity) > 0 {
+		filter["quantity"] = quantity
+	}
+
+	return filter
 
 }
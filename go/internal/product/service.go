@@ -1,68 +1,204 @@
 package product
 
-import "MicroserviceTemplate/internal/domain"
+import (
+	"MicroserviceTemplate/internal/domain"
+	"MicroserviceTemplate/pkg/pagination"
+	"context"
+)
 
 // ? ====================== Interfaces ====================== ?
 
 type IService interface {
-	GetAll() (*domain.Products, error)
-	GetByID(id string) (*domain.Product, error)
-	Save(product *domain.Product) (domain.Product, error)
-	Update(product *domain.Product) error
-	PatchUpdate(product *domain.Product) error
-	Delete(id string) error
+	GetAll(ctx context.Context, query *domain.ProductQuery) (*domain.ProductPage, error)
+	GetAllAfter(ctx context.Context, query *domain.ProductQuery, after string) (*domain.ProductCursorPage, error)
+	GetByID(ctx context.Context, id string) (*domain.Product, error)
+	Save(ctx context.Context, product *domain.Product) (domain.Product, error)
+	Update(ctx context.Context, product *domain.Product, expectedVersion int64) (*domain.Product, error)
+	PatchUpdate(ctx context.Context, id string, patch []byte, patchType string, expectedVersion int64) (*domain.Product, error)
+	Delete(ctx context.Context, id string, expectedVersion int64) error
 }
 
 // ? ====================== Estructuras ====================== ?
 
 type Service struct {
 	repository IRepository
+	tokens     pagination.ITokenCodec
 }
 
 // ? ====================== Structs ====================== ?
 
-func NewService(repository IRepository) IService {
-	return &Service{repository}
+func NewService(repository IRepository, tokens pagination.ITokenCodec) IService {
+	return &Service{repository, tokens}
 }
 
 // ? ====================== Methods ====================== ?
 
-// GetAll returns all products
-func (s *Service) GetAll() (*domain.Products, error) {
-	return s.repository.GetAll()
+// GetAll returns a page of the products that match the query
+func (s *Service) GetAll(ctx context.Context, query *domain.ProductQuery) (*domain.ProductPage, error) {
+
+	normalizePageSize(query)
+
+	products, total, err := s.repository.GetAll(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.ProductPage{
+		Items:      *products,
+		Page:       query.Page,
+		Size:       query.Size,
+		Total:      total,
+		TotalPages: int((total + int64(query.Size) - 1) / int64(query.Size)),
+	}
+
+	if page.Page < page.TotalPages-1 && len(page.Items) > 0 {
+		page.NextToken, err = s.nextToken(page.Items, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+
+}
+
+// * =========== *
+
+// GetAllAfter returns the products that come after the continuation token, an empty token starts from the beginning
+func (s *Service) GetAllAfter(ctx context.Context, query *domain.ProductQuery, after string) (*domain.ProductCursorPage, error) {
+
+	normalizePageSize(query)
+
+	if after != "" {
+
+		var cursor domain.ProductCursor
+		if err := s.tokens.Decode(after, &cursor); err != nil {
+			return nil, ErrInvalidToken
+		}
+
+		if _, ok := domain.ProductSortFields[cursor.SortField]; !ok {
+			return nil, ErrInvalidToken
+		}
+
+		query.SortField = cursor.SortField
+		query.SortOrder = cursor.SortOrder
+		query.After = &cursor
+
+	}
+
+	// One extra product is requested to know whether there is anything left after this page
+	size := query.Size
+	query.Size++
+
+	products, err := s.repository.GetAfter(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.ProductCursorPage{
+		Items: *products,
+		Size:  size,
+	}
+
+	if len(page.Items) > size {
+		page.Items = page.Items[:size]
+		page.NextToken, err = s.nextToken(page.Items, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+
 }
 
 // * =========== *
 
 // GetByID returns a product by its ID
-func (s *Service) GetByID(id string) (*domain.Product, error) {
-	return s.repository.GetByID(id)
+func (s *Service) GetByID(ctx context.Context, id string) (*domain.Product, error) {
+	return s.repository.GetByID(ctx, id)
 }
 
 // * =========== *
 
 // Save saves a product
-func (s *Service) Save(product *domain.Product) (domain.Product, error) {
-	return s.repository.Save(product)
+func (s *Service) Save(ctx context.Context, product *domain.Product) (domain.Product, error) {
+
+	if err := validateProduct(product); err != nil {
+		return domain.Product{}, err
+	}
+
+	return s.repository.Save(ctx, product)
+
 }
 
 // * =========== *
 
-// Update update a product
-func (s *Service) Update(product *domain.Product) error {
-	return s.repository.Update(product)
+// Update update a product if it still has the expected version
+func (s *Service) Update(ctx context.Context, product *domain.Product, expectedVersion int64) (*domain.Product, error) {
+
+	if err := validateProduct(product); err != nil {
+		return nil, err
+	}
+
+	return s.repository.Update(ctx, product, expectedVersion)
+
+}
+
+// * =========== *
+
+// PatchUpdate applies a JSON Merge Patch or JSON Patch document to a product if it still has the expected version
+func (s *Service) PatchUpdate(ctx context.Context, id string, patch []byte, patchType string, expectedVersion int64) (*domain.Product, error) {
+
+	productToUpdate, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if expectedVersion != AnyVersion && productToUpdate.Version != expectedVersion {
+		return nil, ErrVersionMismatch
+	}
+
+	productPatched, err := applyPatch(productToUpdate, patch, patchType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateProduct(productPatched); err != nil {
+		return nil, err
+	}
+
+	// The version that was read is expected so nothing written in between is overwritten
+	return s.repository.Update(ctx, productPatched, productToUpdate.Version)
+
 }
 
 // * =========== *
 
-// PatchUpdate partially update a product (only the fields to be sent)
-func (s *Service) PatchUpdate(product *domain.Product) error {
-	return s.repository.PatchUpdate(product)
+// Delete eliminates a product by its ID if it still has the expected version
+func (s *Service) Delete(ctx context.Context, id string, expectedVersion int64) error {
+	return s.repository.Delete(ctx, id, expectedVersion)
 }
 
 // * =========== *
 
-// Delete eliminates a product by its ID
-func (s *Service) Delete(id string) error {
-	return s.repository.Delete(id)
+// nextToken returns the continuation token that points after the last of the products
+func (s *Service) nextToken(products domain.Products, query *domain.ProductQuery) (string, error) {
+	last := products[len(products)-1]
+	return s.tokens.Encode(domain.NewProductCursor(last, query.SortField, query.SortOrder))
+}
+
+// ? ====================== Functions ====================== ?
+
+// normalizePageSize keeps the page size of the query between one and the maximum page size
+func normalizePageSize(query *domain.ProductQuery) {
+
+	if query.Size <= 0 {
+		query.Size = domain.DefaultPageSize
+	}
+
+	if query.Size > domain.MaxPageSize {
+		query.Size = domain.MaxPageSize
+	}
+
 }
@@ -0,0 +1,99 @@
+package product
+
+import (
+	"MicroserviceTemplate/internal/domain"
+	"MicroserviceTemplate/pkg/web"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// ? ==================== Variables ==================== ?
+
+// productValidator checks the rules declared in the validate tags of the domain structs
+var productValidator = newProductValidator()
+
+// ? ==================== Functions ==================== ?
+
+// newProductValidator returns a validator that reports fields by their JSON names and knows the custom rules
+func newProductValidator() *validator.Validate {
+
+	v := validator.New()
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// currency accepts amounts with at most two decimal places
+	_ = v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		cents := fl.Field().Float() * 100
+		return math.Abs(cents-math.Round(cents)) < 1e-6
+	})
+
+	return v
+
+}
+
+// * =========== *
+
+// validateProduct checks the product against its declared rules and lists every field that breaks them
+func validateProduct(product *domain.Product) error {
+
+	err := productValidator.Struct(product)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return NewError(KindValidation, err.Error(), err)
+	}
+
+	fields := make([]web.FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		fields = append(fields, web.FieldError{
+			Field:   fieldError.Field(),
+			Rule:    fieldError.Tag(),
+			Message: fieldMessage(fieldError),
+		})
+	}
+
+	validationError := NewError(KindValidation, "the product is not valid", err)
+	validationError.Fields = fields
+
+	return validationError
+
+}
+
+// * =========== *
+
+// fieldMessage returns a readable description of the broken rule
+func fieldMessage(fieldError validator.FieldError) string {
+
+	field := fieldError.Field()
+
+	switch fieldError.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "min":
+		return fmt.Sprintf("%s must be at least %s characters long", field, fieldError.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s characters long", field, fieldError.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, fieldError.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fieldError.Param())
+	case "currency":
+		return fmt.Sprintf("%s must have at most two decimal places", field)
+	default:
+		return fmt.Sprintf("%s does not satisfy the %s rule", field, fieldError.Tag())
+	}
+
+}
@@ -1,15 +1,25 @@
 package main
 
 import (
+	handlerActuator "MicroserviceTemplate/cmd/handler/actuator"
+	handlerHealth "MicroserviceTemplate/cmd/handler/health"
 	handlerProduct "MicroserviceTemplate/cmd/handler/product"
+	routerActuator "MicroserviceTemplate/cmd/router/actuator"
+	routerHealth "MicroserviceTemplate/cmd/router/health"
 	routerProduct "MicroserviceTemplate/cmd/router/product"
 	"MicroserviceTemplate/config"
 	_ "MicroserviceTemplate/docs"
 	"MicroserviceTemplate/internal/product"
 	"MicroserviceTemplate/pkg/eureka"
+	"MicroserviceTemplate/pkg/health"
+	"MicroserviceTemplate/pkg/loadbalancer"
+	"MicroserviceTemplate/pkg/metrics"
 	"MicroserviceTemplate/pkg/middleware"
+	"MicroserviceTemplate/pkg/pagination"
+	"MicroserviceTemplate/pkg/registry"
 	store "MicroserviceTemplate/pkg/store/product"
 	"context"
+	"errors"
 	_ "github.com/dimiro1/banner/autoload"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,7 +30,10 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // @title 							ms-template-mongo-go
@@ -36,62 +49,105 @@ import (
 // @BasePath  						/
 func main() {
 
-	ctx := context.Background()
-
-	_ = fx.New(
+	fx.New(
 		fx.Provide(
+			config.NewAppConfig,
+			config.NewSections,
 			store.NewStore,
 			product.NewRepository,
+			pagination.NewTokenCodec,
 			product.NewService,
 			handlerProduct.NewHandler,
 			routerProduct.NewProductRouter,
+			fx.Annotate(health.NewMongoChecker, fx.ResultTags(`group:"health_checkers"`)),
+			fx.Annotate(health.NewConfigServerChecker, fx.ResultTags(`group:"health_checkers"`)),
+			fx.Annotate(health.NewKeycloakChecker, fx.ResultTags(`group:"health_checkers"`)),
+			fx.Annotate(health.NewEurekaChecker, fx.ResultTags(`group:"health_checkers"`)),
+			fx.Annotate(health.NewHealth, fx.ParamTags(`group:"health_checkers"`)),
+			handlerHealth.NewHandler,
+			routerHealth.NewHealthRouter,
+			config.NewRefresher,
+			handlerActuator.NewHandler,
+			routerActuator.NewActuatorRouter,
+			eureka.NewClient,
+			eureka.NewDiscoveryClient,
+			registry.NewServiceRegistry,
+			loadbalancer.NewLoadBalancer,
 		),
 		fx.Invoke(
+			LoadConfiguration,
 			LifecycleHooks,
 		),
+		fx.StopTimeout(time.Minute),
 		fx.NopLogger,
-	).Start(ctx)
+	).Run()
 
 }
 
-// LifecycleHooks - Initializes application hooks in the application life cycle.
-func LifecycleHooks(lc fx.Lifecycle, router routerProduct.IRouter) {
-	lc.Append(fx.Hook{
-		OnStart: func(c context.Context) error {
+// LoadConfiguration - Loads the configuration from the config server before any component is built.
+func LoadConfiguration() {
 
-			// ? ================== Load configuration ================== ?
+	// The command line arguments override every other source of configuration
+	err := config.LoadCommandLine(os.Args[1:])
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-			vp := viper.New()
+	// The local settings are kept as the base the remote configuration is laid over
+	err = config.LoadLocalConfiguration("./resources")
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-			vp.SetConfigName("application")
-			vp.SetConfigType("yaml")
-			vp.AddConfigPath("./resources")
+	config.LoadConfigurationFromBranch(
+		viper.GetString("application.config.import"),
+		viper.GetString("application.name"),
+		strings.Join(config.ActiveProfiles(), ","),
+		viper.GetString("application.config.branch"),
+	)
 
-			err := vp.ReadInConfig()
-			if err != nil {
-				log.Fatalln(err)
-			}
+}
+
+// LifecycleHooks - Initializes application hooks in the application life cycle.
+// The hooks are stopped in reverse order: the configuration polling and the registry refresh are stopped first, then
+// the instance is deregistered from the service registry, the in-flight requests are drained and finally the MongoDB
+// connections are closed.
+func LifecycleHooks(lc fx.Lifecycle, application config.ApplicationConfig, serverConfig config.ServerConfig, keycloak config.KeycloakConfig, security config.SecurityConfig, router routerProduct.IRouter, healthRouter routerHealth.IRouter, actuatorRouter routerActuator.IRouter, productStore store.IProductStore, registryConfig config.RegistryConfig, eurekaConfig config.EurekaConfig, eurekaClient eureka.IClient, discoveryClient eureka.IDiscoveryClient, serviceRegistry registry.IServiceRegistry, refresher config.IRefresher, healthAggregate health.IHealth) {
 
-			config.LoadConfigurationFromBranch(
-				vp.GetString("application.config.import"),
-				vp.GetString("application.name"),
-				vp.GetString("application.config.profile"),
-				vp.GetString("application.config.branch"),
-			)
+	appName := application.Name
+	appId := uuid.New().String()
 
-			// ==================== Start server ==================== ?
+	server := &http.Server{}
+	var port int
 
-			appName := viper.GetString("application.name")
-			port := viper.GetString("server.port")
-			appId := uuid.New().String()
+	// ? ================== MongoDB ================== ?
+
+	lc.Append(fx.Hook{
+		OnStop: func(c context.Context) error {
+			return productStore.Disconnect(c)
+		},
+	})
+
+	// ? ================== HTTP server ================== ?
+
+	lc.Append(fx.Hook{
+		OnStart: func(c context.Context) error {
 
 			gin.SetMode(gin.ReleaseMode)
 			r := gin.Default()
-			r.Use(middleware.IsAuthorizedJWT("/swagger/*any"))
+			r.Use(metrics.Middleware())
+			r.Use(middleware.ProblemDetails())
+			r.Use(middleware.IsAuthorizedJWT(keycloak, "/swagger/*any", "/health/**", "/metrics", "/actuator/prometheus"))
 			r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
+			r.GET("/metrics", metrics.Handler())
+			r.GET("/actuator/prometheus", metrics.Handler())
 			r = router.GetRoutes(r)
+			r = healthRouter.GetRoutes(r)
+			r = actuatorRouter.GetRoutes(r)
+
+			server.Handler = r
 
-			ln, err := net.Listen("tcp", ":"+port)
+			ln, err := net.Listen("tcp", ":"+strconv.Itoa(serverConfig.Port))
 			if err != nil {
 				return err
 			}
@@ -101,26 +157,153 @@ func LifecycleHooks(lc fx.Lifecycle, router routerProduct.IRouter) {
 				return err
 			}
 
-			log.Printf("listening on port %s", portObtained)
-
-			portObtainedInt, err := strconv.Atoi(portObtained)
+			port, err = strconv.Atoi(portObtained)
 			if err != nil {
 				return err
 			}
 
-			eureka.StartClient(appName, appId, portObtainedInt)
+			log.Printf("listening on port %s", portObtained)
+
+			go func() {
+
+				var err error
+				if serverConfig.SSL.Enabled {
+					err = server.ServeTLS(ln, serverConfig.SSL.CertFile, serverConfig.SSL.KeyFile)
+				} else {
+					err = server.Serve(ln)
+				}
+
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Fatalln(err)
+				}
+
+			}()
+
+			return nil
+
+		},
+		OnStop: func(c context.Context) error {
 
-			err = http.Serve(ln, r)
+			drainTimeout := serverConfig.ShutdownTimeout
+
+			log.Printf("draining in-flight requests for up to %s", drainTimeout)
+
+			ctx, cancel := context.WithTimeout(c, drainTimeout)
+			defer cancel()
+
+			return server.Shutdown(ctx)
+
+		},
+	})
+
+	// ? ================== Service registry ================== ?
+
+	var instance registry.Instance
+
+	lc.Append(fx.Hook{
+		OnStart: func(c context.Context) error {
+
+			// The status of the instance in Eureka follows its readiness, unless an operator overrides it
+			if eurekaConfig.Client.Healthcheck.Enabled {
+				eurekaClient.SetStatusProvider(func(ctx context.Context) string {
+					return healthAggregate.Readiness(ctx).Status
+				})
+			}
+
+			// The TTL check in Consul follows the readiness as well, it warns while the instance is not ready
+			if consulRegistry, ok := serviceRegistry.(*registry.ConsulRegistry); ok {
+				consulRegistry.SetStatusProvider(func(ctx context.Context) string {
+					return healthAggregate.Readiness(ctx).Status
+				})
+			}
+
+			instance = registry.NewLocalInstance(appId, appName, port, serverConfig)
+
+			// A registry that cannot be reached does not stop the application, the heartbeats register it again
+			err := serviceRegistry.Register(instance)
 			if err != nil {
-				return err
+				log.Printf("couldn't register the instance: %s", err.Error())
 			}
 
 			return nil
 
 		},
 		OnStop: func(c context.Context) error {
+
 			log.Print("stopping...")
+
+			if err := serviceRegistry.Deregister(instance); err != nil {
+				log.Printf("couldn't deregister the instance: %s", err.Error())
+			}
+
+			return nil
+
+		},
+	})
+
+	// ? ================== Eureka discovery ================== ?
+
+	if registryConfig.Type == registry.TypeEureka && eurekaConfig.Client.FetchRegistry {
+		lc.Append(fx.Hook{
+			OnStart: func(c context.Context) error {
+				discoveryClient.Start()
+				return nil
+			},
+			OnStop: func(c context.Context) error {
+				discoveryClient.Stop()
+				return nil
+			},
+		})
+	}
+
+	// ? ================== Configuration refresh ================== ?
+
+	lc.Append(fx.Hook{
+		OnStart: func(c context.Context) error {
+			ConfigurationListeners(refresher, security, productStore)
+			refresher.StartPolling(application.Config.Refresh.Interval)
+			return nil
+		},
+		OnStop: func(c context.Context) error {
+			refresher.StopPolling()
 			return nil
 		},
 	})
+
+}
+
+// ConfigurationListeners - Applies the configuration that can change at runtime and subscribes to its changes.
+// The configuration is bound and validated again after every change, an invalid one is not applied. The log level and
+// the MongoDB pool size are not refreshed: the application logs with the standard logger, which has no levels, and the
+// driver cannot resize the pool of a connected client, so database.pool needs a restart.
+func ConfigurationListeners(refresher config.IRefresher, security config.SecurityConfig, productStore store.IProductStore) {
+
+	middleware.SetExcludedPaths(security.ExcludedPaths...)
+
+	refresher.Subscribe("security.excluded-paths", func(changes []config.Change) {
+
+		appConfig, err := config.NewAppConfig()
+		if err != nil {
+			log.Printf("keeping the paths excluded from authorization. error: %s", err.Error())
+			return
+		}
+
+		middleware.SetExcludedPaths(appConfig.Security.ExcludedPaths...)
+		log.Printf("paths excluded from authorization changed to %v", appConfig.Security.ExcludedPaths)
+
+	})
+
+	refresher.Subscribe("database.timeouts", func(changes []config.Change) {
+
+		appConfig, err := config.NewAppConfig()
+		if err != nil {
+			log.Printf("keeping the MongoDB operation timeouts. error: %s", err.Error())
+			return
+		}
+
+		productStore.SetTimeouts(appConfig.Database.Timeouts)
+		log.Print("the MongoDB operation timeouts changed, they are applied from the next operation")
+
+	})
+
 }
@@ -0,0 +1,411 @@
+package eureka
+
+import (
+	"MicroserviceTemplate/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/procyon-projects/chrono"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ? ==================== Interfaces ==================== ?
+
+type IDiscoveryClient interface {
+	GetInstances(appName string) []InstanceDetails
+	GetApplications() []string
+	Refresh() error
+	Start()
+	Stop()
+}
+
+// ? ==================== Structs ==================== ?
+
+// DiscoveryClient keeps a local cache of the Eureka registry, fetched in full once and then kept fresh with deltas
+type DiscoveryClient struct {
+	peers        *peers
+	interval     time.Duration
+	disableDelta bool
+	onlyUp       bool
+	client       *http.Client
+
+	mu           sync.RWMutex
+	applications map[string]map[string]InstanceDetails
+	fetched      bool
+	task         chrono.ScheduledTask
+}
+
+// * =========== *
+
+// ApplicationsResponse is the body of GET /apps and GET /apps/delta
+type ApplicationsResponse struct {
+	Applications Applications `json:"applications"`
+}
+
+// * =========== *
+
+type Applications struct {
+	VersionsDelta string          `json:"versions__delta"`
+	AppsHashcode  string          `json:"apps__hashcode"`
+	Application   ApplicationList `json:"application"`
+}
+
+// * =========== *
+
+type Application struct {
+	Name     string       `json:"name"`
+	Instance InstanceList `json:"instance"`
+}
+
+// ? ==================== Types ==================== ?
+
+// ApplicationList is a list of applications, Eureka may send a single application as an object instead of a list
+type ApplicationList []Application
+
+// * =========== *
+
+// InstanceList is a list of instances, Eureka may send a single instance as an object instead of a list
+type InstanceList []InstanceDetails
+
+// ? ==================== Constants ==================== ?
+
+const (
+	StatusUp           = "UP"
+	StatusDown         = "DOWN"
+	StatusStarting     = "STARTING"
+	StatusOutOfService = "OUT_OF_SERVICE"
+	StatusUnknown      = "UNKNOWN"
+
+	ActionAdded    = "ADDED"
+	ActionModified = "MODIFIED"
+	ActionDeleted  = "DELETED"
+)
+
+// ? ==================== Constructors ==================== ?
+
+// NewDiscoveryClient returns a new client of the registry of the Eureka servers of the cluster
+func NewDiscoveryClient(eureka config.EurekaConfig) IDiscoveryClient {
+	return &DiscoveryClient{
+		peers:        newPeers(eureka),
+		interval:     time.Duration(eureka.Client.RegistryFetchIntervalSeconds) * time.Second,
+		disableDelta: eureka.Client.DisableDelta,
+		onlyUp:       eureka.Client.FilterOnlyUpInstances,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		applications: map[string]map[string]InstanceDetails{},
+	}
+}
+
+// ? ==================== Methods ==================== ?
+
+// GetInstances returns the instances of the application from the local cache, only the UP ones unless
+// filter-only-up-instances is disabled
+func (dc *DiscoveryClient) GetInstances(appName string) []InstanceDetails {
+
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	var instances []InstanceDetails
+
+	for _, instance := range dc.applications[strings.ToUpper(appName)] {
+		if !dc.onlyUp || instance.Status == StatusUp {
+			instances = append(instances, instance)
+		}
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].InstanceId < instances[j].InstanceId
+	})
+
+	return instances
+
+}
+
+// * =========== *
+
+// GetApplications returns the names of the applications in the local cache
+func (dc *DiscoveryClient) GetApplications() []string {
+
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	names := make([]string, 0, len(dc.applications))
+	for name := range dc.applications {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+
+}
+
+// * =========== *
+
+// Refresh updates the local cache. The first fetch gets the whole registry, the next ones only get the changes and
+// fall back to the whole registry when the cache does not match the hash of the server after applying them.
+func (dc *DiscoveryClient) Refresh() error {
+
+	dc.mu.RLock()
+	fetched := dc.fetched
+	dc.mu.RUnlock()
+
+	if !fetched || dc.disableDelta {
+		return dc.fetchFull()
+	}
+
+	delta, err := dc.fetch("/apps/delta")
+	if err != nil {
+		return err
+	}
+
+	dc.mu.Lock()
+	dc.applyDelta(delta.Applications)
+	hash := dc.hashCode()
+	dc.mu.Unlock()
+
+	if hash != delta.Applications.AppsHashcode {
+		log.Printf("the Eureka registry cache is out of sync (local %s, server %s), fetching the whole registry", hash, delta.Applications.AppsHashcode)
+		return dc.fetchFull()
+	}
+
+	return nil
+
+}
+
+// * =========== *
+
+// Start fetches the registry and keeps it fresh every registry-fetch-interval-seconds until Stop is called
+func (dc *DiscoveryClient) Start() {
+
+	if err := dc.Refresh(); err != nil {
+		log.Printf("couldn't fetch the Eureka registry: %s", err.Error())
+	}
+
+	taskScheduler := chrono.NewDefaultTaskScheduler()
+
+	task, err := taskScheduler.ScheduleWithFixedDelay(func(ctx context.Context) {
+		if err := dc.Refresh(); err != nil {
+			log.Printf("couldn't refresh the Eureka registry, keeping the cached one: %s", err.Error())
+		}
+	}, dc.interval, chrono.WithTime(time.Now().Add(dc.interval)))
+
+	if err != nil {
+		log.Printf("couldn't schedule the Eureka registry refresh: %s", err.Error())
+		return
+	}
+
+	dc.mu.Lock()
+	dc.task = task
+	dc.mu.Unlock()
+
+}
+
+// * =========== *
+
+// Stop stops refreshing the registry
+func (dc *DiscoveryClient) Stop() {
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if dc.task != nil {
+		dc.task.Cancel()
+		dc.task = nil
+	}
+
+}
+
+// * =========== *
+
+// fetchFull replaces the local cache with the whole registry
+func (dc *DiscoveryClient) fetchFull() error {
+
+	response, err := dc.fetch("/apps")
+	if err != nil {
+		return err
+	}
+
+	applications := map[string]map[string]InstanceDetails{}
+
+	for _, application := range response.Applications.Application {
+		for _, instance := range application.Instance {
+			putInstance(applications, application.Name, instance)
+		}
+	}
+
+	dc.mu.Lock()
+	dc.applications = applications
+	dc.fetched = true
+	dc.mu.Unlock()
+
+	return nil
+
+}
+
+// * =========== *
+
+// fetch requests the applications of the path of the Eureka servers
+func (dc *DiscoveryClient) fetch(path string) (*ApplicationsResponse, error) {
+
+	resp, err := dc.peers.do(dc.client, func(serviceUrl string) (*http.Request, error) {
+
+		req, err := http.NewRequest(http.MethodGet, serviceUrl+path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Accept", "application/json")
+
+		return req, nil
+
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("the Eureka server answered %s with status %d", path, resp.StatusCode)
+	}
+
+	var response ApplicationsResponse
+
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse the applications of the Eureka server: %w", err)
+	}
+
+	return &response, nil
+
+}
+
+// * =========== *
+
+// applyDelta applies the added, modified and deleted instances to the local cache, the caller must hold the lock
+func (dc *DiscoveryClient) applyDelta(delta Applications) {
+
+	for _, application := range delta.Application {
+		for _, instance := range application.Instance {
+
+			switch instance.ActionType {
+			case ActionDeleted:
+				name := strings.ToUpper(application.Name)
+				delete(dc.applications[name], instance.InstanceId)
+				if len(dc.applications[name]) == 0 {
+					delete(dc.applications, name)
+				}
+			default:
+				putInstance(dc.applications, application.Name, instance)
+			}
+
+		}
+	}
+
+}
+
+// * =========== *
+
+// hashCode returns the hash of the local cache, the caller must hold the lock
+func (dc *DiscoveryClient) hashCode() string {
+
+	var all []InstanceDetails
+	for _, instances := range dc.applications {
+		for _, instance := range instances {
+			all = append(all, instance)
+		}
+	}
+
+	return HashCode(all)
+
+}
+
+// * =========== *
+
+// UnmarshalJSON accepts a single application as well as a list
+func (al *ApplicationList) UnmarshalJSON(data []byte) error {
+	return unmarshalList(data, (*[]Application)(al))
+}
+
+// * =========== *
+
+// UnmarshalJSON accepts a single instance as well as a list
+func (il *InstanceList) UnmarshalJSON(data []byte) error {
+	return unmarshalList(data, (*[]InstanceDetails)(il))
+}
+
+// ? ==================== Functions ==================== ?
+
+// HashCode returns the hash of the instances the same way as the Eureka server: the number of instances by status in
+// alphabetical order, such as DOWN_1_UP_3_
+func HashCode(instances []InstanceDetails) string {
+
+	counts := map[string]int{}
+	for _, instance := range instances {
+		counts[instance.Status]++
+	}
+
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+
+	sort.Strings(statuses)
+
+	var hash strings.Builder
+	for _, status := range statuses {
+		hash.WriteString(status + "_" + strconv.Itoa(counts[status]) + "_")
+	}
+
+	return hash.String()
+
+}
+
+// * =========== *
+
+// putInstance adds the instance to the applications, the action type of the delta is not kept
+func putInstance(applications map[string]map[string]InstanceDetails, appName string, instance InstanceDetails) {
+
+	name := strings.ToUpper(appName)
+
+	if applications[name] == nil {
+		applications[name] = map[string]InstanceDetails{}
+	}
+
+	instance.ActionType = ""
+	applications[name][instance.InstanceId] = instance
+
+}
+
+// * =========== *
+
+// unmarshalList decodes a JSON list, or a JSON object as a list of one element
+func unmarshalList[T any](data []byte, list *[]T) error {
+
+	trimmed := strings.TrimSpace(string(data))
+
+	if strings.HasPrefix(trimmed, "{") {
+
+		var item T
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+
+		*list = []T{item}
+
+		return nil
+
+	}
+
+	return json.Unmarshal(data, list)
+
+}
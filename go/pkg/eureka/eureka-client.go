@@ -1,22 +1,62 @@
 package eureka
 
 import (
+	"MicroserviceTemplate/config"
 	"bytes"
-	"context"
 	"encoding/json"
-	"github.com/procyon-projects/chrono"
-	"github.com/spf13/viper"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"os/signal"
-	"strconv"
+	"sync"
 	"time"
 )
 
+// ? ==================== Interfaces ==================== ?
+
+type IClient interface {
+	RegisterApp(appName string, appId string, port int) error
+	UpdateAppStatus(appName string, appId string, port int, status string) error
+	DeleteApp(appName string, appId string) error
+	StartClient(appName string, appId string, port int)
+	Stop(appName string, appId string, port int)
+	Renew(appName string, appId string, port int) error
+	HeartbeatStatus() HeartbeatStatus
+	SetStatusProvider(provider StatusProvider)
+	InstanceStatus() InstanceStatus
+	OverrideStatus(status string) error
+}
+
 // ? ==================== Structs ==================== ?
 
+// Client registers the instance on the Eureka server and keeps it alive
+type Client struct {
+	peers    *peers
+	instance instance
+	interval time.Duration
+	client   *http.Client
+
+	mu               sync.Mutex
+	heartbeat        HeartbeatStatus
+	registration     registration
+	status           string
+	overriddenStatus string
+	statusProvider   StatusProvider
+	stop             chan struct{}
+	done             chan struct{}
+}
+
+// * =========== *
+
+// StatusError is an answer of the Eureka server with a status other than 2xx
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+// * =========== *
+
 type AppRegistrationBody struct {
 	Instance InstanceDetails `json:"instance"`
 }
@@ -24,19 +64,23 @@ type AppRegistrationBody struct {
 // * =========== *
 
 type InstanceDetails struct {
-	InstanceId       string         `json:"instanceId"`
-	HostName         string         `json:"hostName"`
-	App              string         `json:"app"`
-	VipAddress       string         `json:"vipAddress"`
-	SecureVipAddress string         `json:"secureVipAddress"`
-	IpAddr           string         `json:"ipAddr"`
-	Status           string         `json:"status"`
-	Port             Port           `json:"port"`
-	SecurePort       Port           `json:"securePort"`
-	HealthCheckUrl   string         `json:"healthCheckUrl"`
-	StatusPageUrl    string         `json:"statusPageUrl"`
-	HomePageUrl      string         `json:"homePageUrl"`
-	DataCenterInfo   DataCenterInfo `json:"dataCenterInfo"`
+	InstanceId       string            `json:"instanceId"`
+	HostName         string            `json:"hostName"`
+	App              string            `json:"app"`
+	VipAddress       string            `json:"vipAddress"`
+	SecureVipAddress string            `json:"secureVipAddress"`
+	IpAddr           string            `json:"ipAddr"`
+	Status           string            `json:"status"`
+	OverriddenStatus string            `json:"overriddenStatus,omitempty"`
+	Port             Port              `json:"port"`
+	SecurePort       Port              `json:"securePort"`
+	HealthCheckUrl   string            `json:"healthCheckUrl"`
+	StatusPageUrl    string            `json:"statusPageUrl"`
+	HomePageUrl      string            `json:"homePageUrl"`
+	DataCenterInfo   DataCenterInfo    `json:"dataCenterInfo"`
+	LeaseInfo        LeaseInfo         `json:"leaseInfo"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	ActionType       string            `json:"actionType,omitempty"`
 }
 
 // * =========== *
@@ -53,288 +97,201 @@ type Port struct {
 	Enabled string `json:"@enabled"`
 }
 
-// ? ==================== Functions ==================== ?
-
-// ScheduleHeartbeat sends a heartbeat every 25 seconds to keep track of the instance on the Eureka server
-func ScheduleHeartbeat(appName string, appId string) chrono.ScheduledTask {
-
-	taskScheduler := chrono.NewDefaultTaskScheduler()
+// ? ==================== Constructors ==================== ?
 
-	task, err := taskScheduler.ScheduleWithFixedDelay(func(ctx context.Context) {
-		sendHeartbeat(appName, appId)
-	}, 25*time.Second)
+// NewClient returns a new client of the Eureka servers of the cluster
+func NewClient(eureka config.EurekaConfig, server config.ServerConfig) IClient {
 
-	if err != nil {
-		log.Fatalln(err)
+	interval := time.Duration(eureka.Instance.LeaseRenewalIntervalInSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
 	}
 
-	return task
+	return &Client{
+		peers:    newPeers(eureka),
+		instance: newInstance(eureka, server),
+		interval: interval,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
 
 }
 
-// * =========== *
+// ? ==================== Methods ==================== ?
 
 // RegisterApp register the instance on the Eureka server
-func RegisterApp(appName string, appId string, port int) {
+func (ec *Client) RegisterApp(appName string, appId string, port int) error {
 
 	log.Println("registering app on Eureka server")
 
-	body := buildBody(appName, appId, port, "STARTING")
-
-	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(body)
-
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	server := viper.GetString("eureka.client.service-url.defaultZone")
-	if server == "" {
-		server = "http://localhost:8761/eureka"
-	}
-
-	resp, err := http.Post(server+"/apps/"+appName, "application/json", &buf)
-
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatalln(err)
-		}
-	}(resp.Body)
-
-	responseBody, parseErr := io.ReadAll(resp.Body)
-
-	if parseErr != nil {
-		log.Fatalln(parseErr)
-	}
-
-	if string(responseBody) != "" {
-		log.Println(string(responseBody))
-	}
+	return ec.register(appName, appId, port, StatusStarting)
 
 }
 
 // * =========== *
 
 // UpdateAppStatus updates the status of the instance on the Eureka server
-func UpdateAppStatus(appName string, appId string, port int, status string) {
+func (ec *Client) UpdateAppStatus(appName string, appId string, port int, status string) error {
 
 	log.Println("updating app status")
 
-	body := buildBody(appName, appId, port, status)
+	return ec.register(appName, appId, port, status)
 
-	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(body)
-
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	server := viper.GetString("eureka.client.service-url.defaultZone")
-	if server == "" {
-		server = "http://localhost:8761/eureka"
-	}
-
-	req, err := http.Post(server+"/apps/"+appName, "application/json", &buf)
+}
 
-	if err != nil {
-		log.Fatalln(err)
-	}
+// * =========== *
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatalln(err)
-		}
-	}(req.Body)
+// DeleteApp deletes the Eureka server instance
+func (ec *Client) DeleteApp(appName string, appId string) error {
 
-	responseBody, parseErr := io.ReadAll(req.Body)
+	log.Println("deleting app from Eureka server")
 
-	if parseErr != nil {
-		log.Fatalln(parseErr)
-	}
+	_, err := ec.send(http.MethodDelete, "/apps/"+appName+"/"+appId, nil)
 
-	if string(responseBody) != "" {
-		log.Println(string(responseBody))
-	}
+	return err
 
 }
 
 // * =========== *
 
-// DeleteApp deletes the Eureka server instance
-func DeleteApp(appName string, appId string) {
+// StartClient registers the instance on the Eureka server and keeps it alive with heartbeats until Stop is called. A
+// Eureka server that cannot be reached never stops the application, the heartbeats register the instance again once it
+// is back.
+func (ec *Client) StartClient(appName string, appId string, port int) {
 
-	log.Println("deleting app from Eureka server")
+	log.Println("starting Eureka client")
 
-	server := viper.GetString("eureka.client.service-url.defaultZone")
-	if server == "" {
-		server = "http://localhost:8761/eureka"
-	}
+	ec.mu.Lock()
+	ec.registration = registration{appName, appId, port}
+	ec.mu.Unlock()
 
-	req, err := http.NewRequest(http.MethodDelete, server+"/apps/"+appName+"/"+appId, nil)
+	status := ec.localStatus()
 
-	if err != nil {
-		log.Fatalln(err)
+	if err := ec.RegisterApp(appName, appId, port); err != nil {
+		log.Printf("couldn't register the instance on the Eureka server, the heartbeats will retry: %s", err.Error())
+	} else if err := ec.UpdateAppStatus(appName, appId, port, status); err != nil {
+		log.Printf("couldn't update the status on the Eureka server, the heartbeats will retry: %s", err.Error())
+	} else {
+		ec.setStatus(status)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
 
-	if err != nil {
-		log.Fatalln(err)
+	if ec.stop != nil {
+		return
 	}
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatalln(err)
-		}
-	}(resp.Body)
-
-	responseBody, parseErr := io.ReadAll(resp.Body)
+	ec.stop = make(chan struct{})
+	ec.done = make(chan struct{})
 
-	if parseErr != nil {
-		log.Fatalln(parseErr)
-	}
-
-	if string(responseBody) != "" {
-		log.Println(string(responseBody))
-	}
+	go ec.heartbeatLoop(appName, appId, port, ec.stop, ec.done)
 
 }
 
 // * =========== *
 
-// sendHeartbeat sends a heartbeat to keep track of the instance on the Eureka server
-func sendHeartbeat(appName string, appId string) {
+// Stop stops the heartbeats, marks the instance as DOWN and deregisters it from the Eureka server
+func (ec *Client) Stop(appName string, appId string, port int) {
 
-	server := viper.GetString("eureka.client.service-url.defaultZone")
-	if server == "" {
-		server = "http://localhost:8761/eureka"
-	}
+	log.Println("stopping Eureka client")
 
-	req, err := http.NewRequest("PUT", server+"/apps/"+appName+"/"+appId, nil)
+	ec.mu.Lock()
+	stop, done := ec.stop, ec.done
+	ec.stop, ec.done = nil, nil
+	ec.mu.Unlock()
 
-	if err != nil {
-		log.Fatalln(err)
+	if stop != nil {
+		close(stop)
+		<-done
 	}
 
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		log.Fatalln(err)
+	if err := ec.UpdateAppStatus(appName, appId, port, StatusDown); err != nil {
+		log.Printf("couldn't mark the instance as DOWN on the Eureka server: %s", err.Error())
+	} else {
+		ec.setStatus(StatusDown)
 	}
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatalln(err)
-		}
-	}(resp.Body)
-
-	responseBody, parseErr := io.ReadAll(resp.Body)
+	// The other instances stop sending requests once their registry is refreshed without this instance being UP
+	time.Sleep(5 * time.Second)
 
-	if parseErr != nil {
-		log.Fatalln(parseErr)
-	}
-
-	if string(responseBody) != "" {
-		log.Println(string(responseBody))
+	if err := ec.DeleteApp(appName, appId); err != nil {
+		log.Printf("couldn't deregister the instance from the Eureka server: %s", err.Error())
 	}
 
 }
 
 // * =========== *
 
-// buildBody constructs the body of the request to register the instance on the Eureka server
-func buildBody(appName string, appId string, port int, status string) *AppRegistrationBody {
-
-	hostname := viper.GetString("server.hostname")
-	httpport := port
-
-	if hostname == "" {
+// register sends the instance with the status to the Eureka server
+func (ec *Client) register(appName string, appId string, port int, status string) error {
 
-		hostname = "localhost"
-		httpport = 8080
+	var buf bytes.Buffer
 
+	err := json.NewEncoder(&buf).Encode(ec.buildBody(appName, appId, port, status))
+	if err != nil {
+		return err
 	}
 
-	homePageUrl := "http://" + hostname + ":" + strconv.Itoa(httpport)
-	statusPageUrl := "http://" + hostname + ":" + strconv.Itoa(httpport) + "/swagger/index.html"
-	healthCheckUrl := "http://" + hostname + ":" + strconv.Itoa(httpport) + "/swagger/index.html"
-
-	dataCenterInfo := DataCenterInfo{Class: "com.netflix.appinfo.InstanceInfo$DefaultDataCenterInfo", Name: "MyOwn"}
+	_, err = ec.send(http.MethodPost, "/apps/"+appName, buf.Bytes())
 
-	basePort := Port{Port: httpport, Enabled: "true"}
-	securePort := Port{Port: httpport, Enabled: "false"}
-
-	instance := InstanceDetails{InstanceId: appId, HostName: hostname, App: appName, VipAddress: appName, SecureVipAddress: appName, IpAddr: hostname, Status: status, Port: basePort, SecurePort: securePort, HealthCheckUrl: healthCheckUrl, StatusPageUrl: statusPageUrl, HomePageUrl: homePageUrl, DataCenterInfo: dataCenterInfo}
-
-	requestBody := &AppRegistrationBody{Instance: instance}
-
-	return requestBody
+	return err
 
 }
 
 // * =========== *
 
-// Init initializes Eureka communication
-func Init(appName string, appId string, port int) chrono.ScheduledTask {
+// send sends the request to the Eureka servers until one of them answers, the statuses other than 2xx are returned as a
+// StatusError
+func (ec *Client) send(method string, path string, body []byte) (int, error) {
 
-	log.Println("initializing Eureka")
+	resp, err := ec.peers.do(ec.client, func(serviceUrl string) (*http.Request, error) {
 
-	// Initializes the Eureka server
+		req, err := http.NewRequest(method, serviceUrl+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
 
-	RegisterApp(appName, appId, port)
-	UpdateAppStatus(appName, appId, port, "UP")
-	return ScheduleHeartbeat(appName, appId)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
 
-}
+		return req, nil
 
-// * =========== *
+	})
+	if err != nil {
+		return 0, err
+	}
 
-// Stop stops Eureka communication
-func Stop(appName string, appId string, port int, task chrono.ScheduledTask) {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
 
-	log.Println("stopping Eureka server")
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
 
-	// Stops communication with the Eureka server.
-	task.Cancel()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &StatusError{method, resp.Request.URL.Path, resp.StatusCode}
+	}
 
-	UpdateAppStatus(appName, appId, port, "DOWN")
-	time.Sleep(5 * time.Second)
-	DeleteApp(appName, appId)
+	if len(responseBody) > 0 {
+		log.Println(string(responseBody))
+	}
+
+	return resp.StatusCode, nil
 
 }
 
 // * =========== *
 
-// StartClient Eureka's client starts
-func StartClient(appName string, appId string, port int) {
-
-	log.Println("starting Eureka client")
-
-	// Initialize the Eureka client
-
-	task := Init(appName, appId, port)
-
-	// Create channel for receiving interrupt signals
-
-	ch := make(chan os.Signal)
-	signal.Notify(ch, os.Interrupt)
+// buildBody constructs the body of the request to register the instance on the Eureka server
+func (ec *Client) buildBody(appName string, appId string, port int, status string) *AppRegistrationBody {
+	return &AppRegistrationBody{Instance: ec.instance.details(appName, appId, port, status)}
+}
 
-	// Wait for interruption signal
-	go func() {
-		select {
-		case sgn := <-ch:
-			_ = sgn
-			Stop(viper.GetString("application.name"), appId, port, task) // Stop Eureka service
-			os.Exit(1)
-		}
-	}()
+// * =========== *
 
+func (se *StatusError) Error() string {
+	return fmt.Sprintf("the Eureka server answered %s %s with status %d", se.Method, se.Path, se.StatusCode)
 }
@@ -0,0 +1,496 @@
+package eurekatest
+
+import (
+	"MicroserviceTemplate/pkg/eureka"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ? ==================== Structs ==================== ?
+
+// Registry is an in-memory Eureka server: it implements the REST endpoints the Eureka clients use, expires the leases
+// that are not renewed in time and keeps the recent changes for the deltas. It records the registrations and the
+// heartbeats of every instance so the tests can assert on them.
+type Registry struct {
+	mu            sync.Mutex
+	leases        map[string]map[string]*lease
+	changes       []change
+	version       int
+	offset        time.Duration
+	unavailable   bool
+	registrations map[string]int
+	heartbeats    map[string]int
+}
+
+// * =========== *
+
+// lease is an instance registered in the registry
+type lease struct {
+	instance    eureka.InstanceDetails
+	lastRenewal time.Time
+}
+
+// * =========== *
+
+// change is an instance added, modified or deleted, kept for the deltas
+type change struct {
+	instance eureka.InstanceDetails
+	at       time.Time
+}
+
+// ? ==================== Constants ==================== ?
+
+const (
+	// defaultLeaseDuration is the lease of the instances that do not send their leaseInfo, the default of Eureka
+	defaultLeaseDuration = 90 * time.Second
+
+	// deltaRetention is how long the changes are sent in the deltas, the default of Eureka
+	deltaRetention = 3 * time.Minute
+)
+
+// ? ==================== Constructors ==================== ?
+
+// NewRegistry returns a new empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		leases:        map[string]map[string]*lease{},
+		registrations: map[string]int{},
+		heartbeats:    map[string]int{},
+	}
+}
+
+// ? ==================== Methods ==================== ?
+
+// ServeHTTP answers the requests to the Eureka REST endpoints, with or without the /eureka prefix
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	path := strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, "/eureka"), "/")
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.unavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	r.evictExpired()
+
+	if len(parts) == 0 || parts[0] != "apps" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && req.Method == http.MethodGet:
+		r.writeApplications(w, r.allInstances())
+	case len(parts) == 2 && parts[1] == "delta" && req.Method == http.MethodGet:
+		r.writeApplications(w, r.recentChanges())
+	case len(parts) == 2 && req.Method == http.MethodGet:
+		r.getApplication(w, parts[1])
+	case len(parts) == 2 && req.Method == http.MethodPost:
+		r.register(w, req, parts[1])
+	case len(parts) == 3 && req.Method == http.MethodPut:
+		r.renew(w, parts[1], parts[2])
+	case len(parts) == 3 && req.Method == http.MethodDelete:
+		r.cancel(w, parts[1], parts[2])
+	case len(parts) == 4 && parts[3] == "status" && req.Method == http.MethodPut:
+		r.overrideStatus(w, parts[1], parts[2], req.URL.Query().Get("value"))
+	case len(parts) == 4 && parts[3] == "status" && req.Method == http.MethodDelete:
+		r.deleteStatusOverride(w, parts[1], parts[2], req.URL.Query().Get("value"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+
+}
+
+// * =========== *
+
+// Instances returns the instances of the application sorted by id, with their status overridden if it is
+func (r *Registry) Instances(appName string) []eureka.InstanceDetails {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.evictExpired()
+
+	var instances []eureka.InstanceDetails
+	for _, l := range r.leases[strings.ToUpper(appName)] {
+		instances = append(instances, l.instance)
+	}
+
+	sortInstances(instances)
+
+	return instances
+
+}
+
+// * =========== *
+
+// Instance returns the instance of the application
+func (r *Registry) Instance(appName string, instanceId string) (eureka.InstanceDetails, bool) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.evictExpired()
+
+	l, ok := r.leases[strings.ToUpper(appName)][instanceId]
+	if !ok {
+		return eureka.InstanceDetails{}, false
+	}
+
+	return l.instance, true
+
+}
+
+// * =========== *
+
+// Registrations returns the number of times the instance was registered, a status update being a registration
+func (r *Registry) Registrations(instanceId string) int {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.registrations[instanceId]
+
+}
+
+// * =========== *
+
+// Heartbeats returns the number of heartbeats the instance sent, including the ones for a lease that had expired
+func (r *Registry) Heartbeats(instanceId string) int {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.heartbeats[instanceId]
+
+}
+
+// * =========== *
+
+// Advance moves the clock of the registry forward, so the leases expire without waiting for them
+func (r *Registry) Advance(d time.Duration) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.offset += d
+
+}
+
+// * =========== *
+
+// SetAvailable makes every request fail with 503 until the registry is available again
+func (r *Registry) SetAvailable(available bool) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unavailable = !available
+
+}
+
+// * =========== *
+
+// register adds the instance, or replaces it keeping the status overridden by an operator
+func (r *Registry) register(w http.ResponseWriter, req *http.Request, appName string) {
+
+	var body eureka.AppRegistrationBody
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Instance.InstanceId == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	name := strings.ToUpper(appName)
+	instance := body.Instance
+	instance.App = name
+	instance.ActionType = ""
+
+	action := eureka.ActionAdded
+
+	if r.leases[name] == nil {
+		r.leases[name] = map[string]*lease{}
+	}
+
+	if previous, ok := r.leases[name][instance.InstanceId]; ok {
+		action = eureka.ActionModified
+		if previous.instance.OverriddenStatus != "" && previous.instance.OverriddenStatus != eureka.StatusUnknown {
+			instance.OverriddenStatus = previous.instance.OverriddenStatus
+			instance.Status = previous.instance.OverriddenStatus
+		}
+	}
+
+	r.leases[name][instance.InstanceId] = &lease{instance: instance, lastRenewal: r.now()}
+	r.registrations[instance.InstanceId]++
+	r.record(instance, action)
+
+	log.Printf("registered instance %s of %s with status %s", instance.InstanceId, name, instance.Status)
+
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+// * =========== *
+
+// renew renews the lease of the instance, 404 tells the client to register it again
+func (r *Registry) renew(w http.ResponseWriter, appName string, instanceId string) {
+
+	r.heartbeats[instanceId]++
+
+	l, ok := r.leases[strings.ToUpper(appName)][instanceId]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	l.lastRenewal = r.now()
+
+	w.WriteHeader(http.StatusOK)
+
+}
+
+// * =========== *
+
+// cancel removes the instance
+func (r *Registry) cancel(w http.ResponseWriter, appName string, instanceId string) {
+
+	if !r.remove(strings.ToUpper(appName), instanceId) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	log.Printf("deregistered instance %s of %s", instanceId, strings.ToUpper(appName))
+
+	w.WriteHeader(http.StatusOK)
+
+}
+
+// * =========== *
+
+// overrideStatus sets the status of the instance whatever the status it registers with
+func (r *Registry) overrideStatus(w http.ResponseWriter, appName string, instanceId string, status string) {
+
+	l, ok := r.leases[strings.ToUpper(appName)][instanceId]
+	if !ok || status == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	l.instance.OverriddenStatus = status
+	l.instance.Status = status
+	r.record(l.instance, eureka.ActionModified)
+
+	w.WriteHeader(http.StatusOK)
+
+}
+
+// * =========== *
+
+// deleteStatusOverride removes the override and sets the status to the value, UNKNOWN when there is none
+func (r *Registry) deleteStatusOverride(w http.ResponseWriter, appName string, instanceId string, status string) {
+
+	l, ok := r.leases[strings.ToUpper(appName)][instanceId]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if status == "" {
+		status = eureka.StatusUnknown
+	}
+
+	l.instance.OverriddenStatus = ""
+	l.instance.Status = status
+	r.record(l.instance, eureka.ActionModified)
+
+	w.WriteHeader(http.StatusOK)
+
+}
+
+// * =========== *
+
+// getApplication answers the instances of a single application
+func (r *Registry) getApplication(w http.ResponseWriter, appName string) {
+
+	name := strings.ToUpper(appName)
+
+	if len(r.leases[name]) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var instances []eureka.InstanceDetails
+	for _, l := range r.leases[name] {
+		instances = append(instances, l.instance)
+	}
+
+	sortInstances(instances)
+
+	writeJSON(w, map[string]eureka.Application{"application": {Name: name, Instance: instances}})
+
+}
+
+// * =========== *
+
+// writeApplications answers the instances grouped by application, with the hash of the whole registry
+func (r *Registry) writeApplications(w http.ResponseWriter, instances []eureka.InstanceDetails) {
+
+	byApp := map[string][]eureka.InstanceDetails{}
+	for _, instance := range instances {
+		byApp[instance.App] = append(byApp[instance.App], instance)
+	}
+
+	names := make([]string, 0, len(byApp))
+	for name := range byApp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	applications := make(eureka.ApplicationList, 0, len(names))
+	for _, name := range names {
+		applications = append(applications, eureka.Application{Name: name, Instance: byApp[name]})
+	}
+
+	writeJSON(w, eureka.ApplicationsResponse{Applications: eureka.Applications{
+		VersionsDelta: strconv.Itoa(r.version),
+		AppsHashcode:  eureka.HashCode(r.allInstances()),
+		Application:   applications,
+	}})
+
+}
+
+// * =========== *
+
+// allInstances returns every instance of the registry sorted by id
+func (r *Registry) allInstances() []eureka.InstanceDetails {
+
+	var instances []eureka.InstanceDetails
+	for _, leases := range r.leases {
+		for _, l := range leases {
+			instances = append(instances, l.instance)
+		}
+	}
+
+	sortInstances(instances)
+
+	return instances
+
+}
+
+// * =========== *
+
+// recentChanges returns the changes of the retention window, the last change of each instance only
+func (r *Registry) recentChanges() []eureka.InstanceDetails {
+
+	latest := map[string]eureka.InstanceDetails{}
+	for _, c := range r.changes {
+		latest[c.instance.InstanceId] = c.instance
+	}
+
+	instances := make([]eureka.InstanceDetails, 0, len(latest))
+	for _, instance := range latest {
+		instances = append(instances, instance)
+	}
+
+	sortInstances(instances)
+
+	return instances
+
+}
+
+// * =========== *
+
+// evictExpired removes the instances whose lease was not renewed in time and forgets the changes older than the
+// retention, the caller must hold the lock
+func (r *Registry) evictExpired() {
+
+	now := r.now()
+
+	for name, leases := range r.leases {
+		for id, l := range leases {
+
+			duration := time.Duration(l.instance.LeaseInfo.DurationInSecs) * time.Second
+			if duration <= 0 {
+				duration = defaultLeaseDuration
+			}
+
+			if now.Sub(l.lastRenewal) > duration {
+				log.Printf("the lease of instance %s of %s expired", id, name)
+				r.remove(name, id)
+			}
+
+		}
+	}
+
+	kept := r.changes[:0]
+	for _, c := range r.changes {
+		if now.Sub(c.at) <= deltaRetention {
+			kept = append(kept, c)
+		}
+	}
+	r.changes = kept
+
+}
+
+// * =========== *
+
+// remove deletes the instance and records the deletion, the caller must hold the lock
+func (r *Registry) remove(name string, instanceId string) bool {
+
+	l, ok := r.leases[name][instanceId]
+	if !ok {
+		return false
+	}
+
+	delete(r.leases[name], instanceId)
+	if len(r.leases[name]) == 0 {
+		delete(r.leases, name)
+	}
+
+	r.record(l.instance, eureka.ActionDeleted)
+
+	return true
+
+}
+
+// * =========== *
+
+// record keeps the change of the instance for the deltas, the caller must hold the lock
+func (r *Registry) record(instance eureka.InstanceDetails, action string) {
+	instance.ActionType = action
+	r.version++
+	r.changes = append(r.changes, change{instance, r.now()})
+}
+
+// * =========== *
+
+// now returns the time of the registry clock
+func (r *Registry) now() time.Time {
+	return time.Now().Add(r.offset)
+}
+
+// ? ==================== Functions ==================== ?
+
+// sortInstances sorts the instances by id
+func sortInstances(instances []eureka.InstanceDetails) {
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].InstanceId < instances[j].InstanceId
+	})
+}
+
+// * =========== *
+
+// writeJSON answers the body as JSON
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,38 @@
+package eurekatest
+
+import (
+	"net/http/httptest"
+)
+
+// ? ==================== Structs ==================== ?
+
+// Server is a fake Eureka server listening on a local port, for the tests of the Eureka clients
+type Server struct {
+	*Registry
+	server *httptest.Server
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewServer starts a new fake Eureka server with an empty registry, Close must be called once the test is done
+func NewServer() *Server {
+
+	registry := NewRegistry()
+
+	return &Server{registry, httptest.NewServer(registry)}
+
+}
+
+// ? ==================== Methods ==================== ?
+
+// URL returns the service URL of the server, the one to set as eureka.client.service-url.defaultZone
+func (s *Server) URL() string {
+	return s.server.URL + "/eureka"
+}
+
+// * =========== *
+
+// Close stops the server
+func (s *Server) Close() {
+	s.server.Close()
+}
@@ -0,0 +1,171 @@
+package eureka
+
+import (
+	"MicroserviceTemplate/pkg/metrics"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// ? ==================== Structs ==================== ?
+
+// HeartbeatStatus is the state of the heartbeats sent to the Eureka server
+type HeartbeatStatus struct {
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastSuccess         time.Time `json:"lastSuccess,omitempty"`
+	LastError           string    `json:"lastError,omitempty"`
+	Reregistrations     int       `json:"reregistrations"`
+}
+
+// ? ==================== Constants ==================== ?
+
+const (
+	// defaultHeartbeatInterval is the delay between two heartbeats when lease-renewal-interval-in-seconds is not set
+	defaultHeartbeatInterval = 30 * time.Second
+
+	// maxBackoffMultiplier bounds the delay after consecutive failures, like exponentialBackOffBound in the Eureka client
+	maxBackoffMultiplier = 10
+)
+
+// ? ==================== Methods ==================== ?
+
+// HeartbeatStatus returns the state of the heartbeats
+func (ec *Client) HeartbeatStatus() HeartbeatStatus {
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	return ec.heartbeat
+
+}
+
+// * =========== *
+
+// heartbeatLoop sends the heartbeats until stop is closed. The delay doubles after each consecutive failure, up to ten
+// times the interval, with a jitter so the instances do not retry all at once after the Eureka server comes back.
+func (ec *Client) heartbeatLoop(appName string, appId string, port int, stop <-chan struct{}, done chan<- struct{}) {
+
+	defer close(done)
+
+	delay := ec.interval
+
+	for {
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(jitter(delay)):
+		}
+
+		ec.syncStatus(appName, appId, port)
+
+		if err := ec.renew(appName, appId, port); err != nil {
+			delay = backoff(delay, ec.interval)
+			continue
+		}
+
+		delay = ec.interval
+
+	}
+
+}
+
+// * =========== *
+
+// Renew sends a heartbeat now, outside of the heartbeats sent every lease-renewal-interval-in-seconds
+func (ec *Client) Renew(appName string, appId string, port int) error {
+	return ec.renew(appName, appId, port)
+}
+
+// * =========== *
+
+// renew sends a heartbeat and registers the instance again when the Eureka server no longer knows it, along with the
+// status set by an operator
+func (ec *Client) renew(appName string, appId string, port int) error {
+
+	err := ec.sendHeartbeat(appName, appId)
+
+	var statusError *StatusError
+	if errors.As(err, &statusError) && statusError.StatusCode == http.StatusNotFound {
+
+		log.Printf("the Eureka server no longer knows instance %s, registering it again", appId)
+
+		status := ec.localStatus()
+
+		err = ec.UpdateAppStatus(appName, appId, port, status)
+		metrics.RecordReregistration(err)
+
+		if err == nil {
+			ec.mu.Lock()
+			ec.heartbeat.Reregistrations++
+			ec.status = status
+			ec.mu.Unlock()
+
+			ec.restoreOverride()
+		}
+
+	}
+
+	ec.recordHeartbeat(err)
+
+	return err
+
+}
+
+// * =========== *
+
+// sendHeartbeat sends a heartbeat to keep track of the instance on the Eureka server
+func (ec *Client) sendHeartbeat(appName string, appId string) error {
+
+	_, err := ec.send(http.MethodPut, "/apps/"+appName+"/"+appId, nil)
+
+	return err
+
+}
+
+// * =========== *
+
+// recordHeartbeat keeps the outcome of a heartbeat in the status and the metrics
+func (ec *Client) recordHeartbeat(err error) {
+
+	metrics.RecordHeartbeat(err)
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	if err != nil {
+		ec.heartbeat.ConsecutiveFailures++
+		ec.heartbeat.LastError = err.Error()
+		log.Printf("heartbeat to the Eureka server failed %d times in a row: %s", ec.heartbeat.ConsecutiveFailures, err.Error())
+	} else {
+		ec.heartbeat.ConsecutiveFailures = 0
+		ec.heartbeat.LastSuccess = time.Now()
+		ec.heartbeat.LastError = ""
+	}
+
+	metrics.RecordHeartbeatFailures(ec.heartbeat.ConsecutiveFailures)
+
+}
+
+// ? ==================== Functions ==================== ?
+
+// backoff returns the delay after another failure
+func backoff(delay time.Duration, interval time.Duration) time.Duration {
+
+	delay *= 2
+	if delay > maxBackoffMultiplier*interval {
+		delay = maxBackoffMultiplier * interval
+	}
+
+	return delay
+
+}
+
+// * =========== *
+
+// jitter returns the delay changed by up to 10% either way
+func jitter(delay time.Duration) time.Duration {
+	return time.Duration(float64(delay) * (0.9 + 0.2*rand.Float64()))
+}
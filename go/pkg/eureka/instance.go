@@ -0,0 +1,157 @@
+package eureka
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/network"
+	"net"
+	"os"
+	"runtime/debug"
+	"strconv"
+)
+
+// ? ==================== Structs ==================== ?
+
+// instance is what the instance registers about itself on the Eureka server, resolved once when the client is created
+type instance struct {
+	hostname   string
+	ipAddress  string
+	preferIP   bool
+	secure     bool
+	renewal    int
+	expiration int
+	metadata   map[string]string
+}
+
+// * =========== *
+
+type LeaseInfo struct {
+	RenewalIntervalInSecs int `json:"renewalIntervalInSecs"`
+	DurationInSecs        int `json:"durationInSecs"`
+}
+
+// ? ==================== Constants ==================== ?
+
+const (
+	MetadataZone           = "zone"
+	MetadataVersion        = "version"
+	MetadataGitCommit      = "git-commit"
+	MetadataManagementPort = "management.port"
+)
+
+// ? ==================== Constructors ==================== ?
+
+// newInstance resolves the hostname, the IP address and the metadata of the instance
+func newInstance(eureka config.EurekaConfig, server config.ServerConfig) instance {
+
+	ipAddress := eureka.Instance.IPAddress
+	if ipAddress == "" {
+		ipAddress = network.OutboundIP()
+	}
+
+	hostname := eureka.Instance.Hostname
+	if hostname == "" {
+		hostname = server.Hostname
+	}
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	if hostname == "" {
+		hostname = ipAddress
+	}
+
+	metadata := buildMetadata()
+	for key, value := range eureka.Instance.MetadataMap {
+		metadata[key] = value
+	}
+
+	return instance{
+		hostname:   hostname,
+		ipAddress:  ipAddress,
+		preferIP:   eureka.Instance.PreferIPAddress,
+		secure:     server.SSL.Enabled,
+		renewal:    eureka.Instance.LeaseRenewalIntervalInSeconds,
+		expiration: eureka.Instance.LeaseExpirationDurationInSeconds,
+		metadata:   metadata,
+	}
+
+}
+
+// ? ==================== Methods ==================== ?
+
+// host returns the host the other services reach the instance at, its IP address when prefer-ip-address is set
+func (i instance) host() string {
+
+	if i.preferIP {
+		return i.ipAddress
+	}
+
+	return i.hostname
+
+}
+
+// * =========== *
+
+// details returns the instance registered with the port and the status. Only the secure port is enabled when TLS is
+// on.
+func (i instance) details(appName string, appId string, port int, status string) InstanceDetails {
+
+	scheme := "http"
+	if i.secure {
+		scheme = "https"
+	}
+
+	baseUrl := scheme + "://" + net.JoinHostPort(i.host(), strconv.Itoa(port))
+
+	metadata := make(map[string]string, len(i.metadata)+1)
+	for key, value := range i.metadata {
+		metadata[key] = value
+	}
+	if _, ok := metadata[MetadataManagementPort]; !ok {
+		metadata[MetadataManagementPort] = strconv.Itoa(port)
+	}
+
+	return InstanceDetails{
+		InstanceId:       appId,
+		HostName:         i.host(),
+		App:              appName,
+		VipAddress:       appName,
+		SecureVipAddress: appName,
+		IpAddr:           i.ipAddress,
+		Status:           status,
+		Port:             Port{Port: port, Enabled: strconv.FormatBool(!i.secure)},
+		SecurePort:       Port{Port: port, Enabled: strconv.FormatBool(i.secure)},
+		HealthCheckUrl:   baseUrl + "/health",
+		StatusPageUrl:    baseUrl + "/swagger/index.html",
+		HomePageUrl:      baseUrl,
+		DataCenterInfo:   DataCenterInfo{Class: "com.netflix.appinfo.InstanceInfo$DefaultDataCenterInfo", Name: "MyOwn"},
+		LeaseInfo:        LeaseInfo{RenewalIntervalInSecs: i.renewal, DurationInSecs: i.expiration},
+		Metadata:         metadata,
+	}
+
+}
+
+// ? ==================== Functions ==================== ?
+
+// buildMetadata returns the version and the git commit the binary was built from, when Go recorded them
+func buildMetadata() map[string]string {
+
+	metadata := map[string]string{}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return metadata
+	}
+
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		metadata[MetadataVersion] = info.Main.Version
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			metadata[MetadataGitCommit] = setting.Value
+		}
+	}
+
+	return metadata
+
+}
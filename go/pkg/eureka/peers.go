@@ -0,0 +1,193 @@
+package eureka
+
+import (
+	"MicroserviceTemplate/config"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ? ==================== Structs ==================== ?
+
+// peers are the Eureka servers of the cluster, in the order they are tried. The requests stick to the server that
+// answered last and move on to the next one when it cannot be reached or fails, like the Spring clients do.
+type peers struct {
+	mu      sync.Mutex
+	urls    []string
+	current int
+}
+
+// ? ==================== Constants ==================== ?
+
+// defaultZone is the zone of the Eureka servers when no availability zone is configured
+const defaultZone = "defaultZone"
+
+// ? ==================== Constructors ==================== ?
+
+// newPeers returns the Eureka servers of the configuration
+func newPeers(eureka config.EurekaConfig) *peers {
+	return &peers{urls: ServiceURLs(eureka)}
+}
+
+// ? ==================== Methods ==================== ?
+
+// do sends the request built for a Eureka server, trying every server once until one answers without a 5xx status. The
+// answer of the last server is returned when they all fail.
+func (p *peers) do(client *http.Client, build func(serviceUrl string) (*http.Request, error)) (*http.Response, error) {
+
+	for attempt := 1; ; attempt++ {
+
+		serviceUrl := p.get()
+
+		req, err := build(serviceUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+
+		if attempt >= len(p.urls) {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		next := p.failover(serviceUrl)
+		log.Printf("the Eureka server %s failed, trying %s", serviceUrl, next)
+
+	}
+
+}
+
+// * =========== *
+
+// get returns the Eureka server the requests are sent to
+func (p *peers) get() string {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.urls[p.current]
+
+}
+
+// * =========== *
+
+// failover moves on to the server after the one that failed, unless another request already did, and returns it
+func (p *peers) failover(failed string) string {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.urls[p.current] == failed {
+		p.current = (p.current + 1) % len(p.urls)
+	}
+
+	return p.urls[p.current]
+
+}
+
+// ? ==================== Functions ==================== ?
+
+// ServiceURLs returns the Eureka servers in the order they are tried: the servers of the zone of the instance first,
+// then the ones of the next availability zones of the region. The servers of defaultZone are used when no zone of the
+// region has any.
+func ServiceURLs(eureka config.EurekaConfig) []string {
+
+	zones := splitList(eureka.Client.AvailabilityZones[strings.ToLower(eureka.Client.Region)])
+	if len(zones) == 0 {
+		zones = []string{defaultZone}
+	}
+
+	offset := zoneOffset(zones, eureka.Instance.MetadataMap["zone"], eureka.Client.PreferSameZoneEureka)
+
+	var urls []string
+	seen := map[string]bool{}
+
+	add := func(list string) {
+		for _, serviceUrl := range splitList(list) {
+			serviceUrl = strings.TrimSuffix(serviceUrl, "/")
+			if !seen[serviceUrl] {
+				seen[serviceUrl] = true
+				urls = append(urls, serviceUrl)
+			}
+		}
+	}
+
+	for i := range zones {
+		add(zoneServiceURLs(eureka.Client.ServiceURL, zones[(offset+i)%len(zones)]))
+	}
+
+	if len(urls) == 0 {
+		add(eureka.Client.ServiceURL.DefaultZone)
+	}
+
+	return urls
+
+}
+
+// * =========== *
+
+// zoneOffset returns the index of the availability zone tried first: the zone of the instance, or the first one, when
+// the servers of the same zone are preferred, the zone after it otherwise
+func zoneOffset(zones []string, instanceZone string, preferSameZone bool) int {
+
+	offset := 0
+	for i, zone := range zones {
+		if strings.EqualFold(zone, instanceZone) {
+			offset = i
+			break
+		}
+	}
+
+	if !preferSameZone && len(zones) > 1 {
+		offset = (offset + 1) % len(zones)
+	}
+
+	return offset
+
+}
+
+// * =========== *
+
+// zoneServiceURLs returns the servers of the zone, the keys are lower case once read by viper
+func zoneServiceURLs(serviceURL config.EurekaServiceURLConfig, zone string) string {
+
+	if strings.EqualFold(zone, defaultZone) {
+		return serviceURL.DefaultZone
+	}
+
+	for name, urls := range serviceURL.Zones {
+		if strings.EqualFold(name, zone) {
+			return urls
+		}
+	}
+
+	return ""
+
+}
+
+// * =========== *
+
+// splitList returns the items of a list separated by commas
+func splitList(list string) []string {
+
+	var items []string
+
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+
+}
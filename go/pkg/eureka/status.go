@@ -0,0 +1,194 @@
+package eureka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// ? ==================== Types ==================== ?
+
+// StatusProvider returns the status the instance reports on its own, such as the aggregate of its health
+type StatusProvider func(ctx context.Context) string
+
+// ? ==================== Structs ==================== ?
+
+// InstanceStatus is the status of the instance on the Eureka server, in the format of the service-registry endpoint of
+// Spring Cloud
+type InstanceStatus struct {
+	Status           string `json:"status"`
+	OverriddenStatus string `json:"overriddenStatus"`
+}
+
+// * =========== *
+
+// registration is the instance started by StartClient
+type registration struct {
+	appName string
+	appId   string
+	port    int
+}
+
+// ? ==================== Variables ==================== ?
+
+var (
+	// ErrNotRegistered is returned when the status is overridden before the client is started
+	ErrNotRegistered = errors.New("the instance is not registered on the Eureka server")
+
+	// ErrInvalidStatus is returned when the status cannot be set by an operator
+	ErrInvalidStatus = errors.New("the status can only be overridden with UP or OUT_OF_SERVICE")
+)
+
+// ? ==================== Methods ==================== ?
+
+// SetStatusProvider makes the status of the instance follow the provider: it is checked before every heartbeat and
+// sent to the Eureka server when it changes
+func (ec *Client) SetStatusProvider(provider StatusProvider) {
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	ec.statusProvider = provider
+
+}
+
+// * =========== *
+
+// InstanceStatus returns the last status sent to the Eureka server and the status set by an operator, UNKNOWN when
+// there is none
+func (ec *Client) InstanceStatus() InstanceStatus {
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	overridden := ec.overriddenStatus
+	if overridden == "" {
+		overridden = StatusUnknown
+	}
+
+	return InstanceStatus{Status: ec.status, OverriddenStatus: overridden}
+
+}
+
+// * =========== *
+
+// OverrideStatus sets the status of the instance with the override API of the Eureka server. OUT_OF_SERVICE takes the
+// instance out of the load balancers whatever its health, UP removes the override so the status follows the health
+// again.
+func (ec *Client) OverrideStatus(status string) error {
+
+	ec.mu.Lock()
+	instance, current := ec.registration, ec.status
+	ec.mu.Unlock()
+
+	if instance.appId == "" {
+		return ErrNotRegistered
+	}
+
+	if current == "" {
+		current = StatusUp
+	}
+
+	path := "/apps/" + instance.appName + "/" + instance.appId + "/status"
+
+	var err error
+
+	switch status {
+	case StatusOutOfService:
+		_, err = ec.send(http.MethodPut, path+"?value="+status, nil)
+	case StatusUp:
+		_, err = ec.send(http.MethodDelete, path+"?value="+current, nil)
+		status = ""
+	default:
+		return fmt.Errorf("%w, got %q", ErrInvalidStatus, status)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ec.mu.Lock()
+	ec.overriddenStatus = status
+	ec.mu.Unlock()
+
+	log.Printf("the status of the instance on the Eureka server is now overridden with %q", status)
+
+	return nil
+
+}
+
+// * =========== *
+
+// localStatus returns the status the instance reports on its own, UP when it has no status provider
+func (ec *Client) localStatus() string {
+
+	ec.mu.Lock()
+	provider := ec.statusProvider
+	ec.mu.Unlock()
+
+	if provider == nil {
+		return StatusUp
+	}
+
+	return provider(context.Background())
+
+}
+
+// * =========== *
+
+// syncStatus sends the status of the instance to the Eureka server when it changed since the last time it was sent
+func (ec *Client) syncStatus(appName string, appId string, port int) {
+
+	status := ec.localStatus()
+
+	ec.mu.Lock()
+	previous := ec.status
+	ec.mu.Unlock()
+
+	if status == previous {
+		return
+	}
+
+	if err := ec.UpdateAppStatus(appName, appId, port, status); err != nil {
+		log.Printf("couldn't update the status of the instance from %s to %s: %s", previous, status, err.Error())
+		return
+	}
+
+	log.Printf("the status of the instance changed from %s to %s", previous, status)
+
+	ec.setStatus(status)
+
+}
+
+// * =========== *
+
+// setStatus records the last status sent to the Eureka server
+func (ec *Client) setStatus(status string) {
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	ec.status = status
+
+}
+
+// * =========== *
+
+// restoreOverride sends again the status set by an operator, after the instance was registered again
+func (ec *Client) restoreOverride() {
+
+	ec.mu.Lock()
+	overridden := ec.overriddenStatus
+	ec.mu.Unlock()
+
+	if overridden == "" {
+		return
+	}
+
+	if err := ec.OverrideStatus(overridden); err != nil {
+		log.Printf("couldn't override the status of the instance again: %s", err.Error())
+	}
+
+}
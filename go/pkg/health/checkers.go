@@ -0,0 +1,225 @@
+package health
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/eureka"
+	"MicroserviceTemplate/pkg/registry"
+	store "MicroserviceTemplate/pkg/store/product"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ? ==================== Structs ==================== ?
+
+// MongoChecker pings the MongoDB server
+type MongoChecker struct {
+	store store.IProductStore
+}
+
+// * =========== *
+
+// HTTPChecker checks that an HTTP endpoint answers with a successful status
+type HTTPChecker struct {
+	name          string
+	url           string
+	client        *http.Client
+	authorization string
+}
+
+// * =========== *
+
+// EurekaChecker reports the state of the heartbeats sent to the Eureka server
+type EurekaChecker struct {
+	client eureka.IClient
+}
+
+// * =========== *
+
+// staticChecker reports the same component every time, for the dependencies that are not used
+type staticChecker struct {
+	name      string
+	component Component
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewMongoChecker returns a new checker of the MongoDB connection
+func NewMongoChecker(store store.IProductStore) IChecker {
+	return &MongoChecker{store}
+}
+
+// * =========== *
+
+// NewHTTPChecker returns a new checker of an HTTP endpoint
+func NewHTTPChecker(name string, url string) IChecker {
+	return &HTTPChecker{name, url, &http.Client{Timeout: 10 * time.Second}, ""}
+}
+
+// * =========== *
+
+// NewConfigServerChecker returns a new checker of the config server the configuration is loaded from
+func NewConfigServerChecker(application config.ApplicationConfig) IChecker {
+
+	// The config server is not needed in offline mode, so it does not make the application unready
+	if application.Config.Offline {
+		return &staticChecker{"configServer", Up(map[string]interface{}{"offline": true})}
+	}
+
+	url := fmt.Sprintf("%s/%s/%s",
+		strings.TrimSuffix(application.Config.Import, "/"),
+		application.Name,
+		application.Config.Profile,
+	)
+
+	checker := &HTTPChecker{"configServer", url, &http.Client{Timeout: 10 * time.Second}, ""}
+
+	// The config server is checked with the same credentials the configuration is loaded with
+	if token := application.Config.Token; token != "" {
+		checker.authorization = "Bearer " + token
+	} else if username := application.Config.Username; username != "" {
+		credentials := username + ":" + application.Config.Password
+		checker.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	return checker
+
+}
+
+// * =========== *
+
+// NewKeycloakChecker returns a new checker of the Keycloak JWKS endpoint the tokens are verified with
+func NewKeycloakChecker(keycloak config.KeycloakConfig) IChecker {
+
+	url := fmt.Sprintf("%s/realms/%s/protocol/openid-connect/certs",
+		strings.TrimSuffix(keycloak.URL, "/"),
+		keycloak.Realm,
+	)
+
+	return NewHTTPChecker("keycloak", url)
+
+}
+
+// * =========== *
+
+// NewEurekaChecker returns a new checker of the heartbeats sent to the Eureka server
+func NewEurekaChecker(registryConfig config.RegistryConfig, client eureka.IClient) IChecker {
+
+	// Eureka is not used when the instance registers in another registry
+	if registryConfig.Type != registry.TypeEureka {
+		return &staticChecker{"eureka", Up(map[string]interface{}{"registry": registryConfig.Type})}
+	}
+
+	return &EurekaChecker{client}
+
+}
+
+// ? ==================== Methods ==================== ?
+
+// Name returns the name of the component
+func (mc *MongoChecker) Name() string {
+	return "mongo"
+}
+
+// * =========== *
+
+// Check pings the primary of the MongoDB deployment
+func (mc *MongoChecker) Check(ctx context.Context) Component {
+
+	if err := mc.store.Ping(ctx); err != nil {
+		return Down(err, nil)
+	}
+
+	return Up(nil)
+
+}
+
+// * =========== *
+
+// Name returns the name of the component
+func (hc *HTTPChecker) Name() string {
+	return hc.name
+}
+
+// * =========== *
+
+// Check sends a GET request to the endpoint
+func (hc *HTTPChecker) Check(ctx context.Context) Component {
+
+	details := map[string]interface{}{"url": hc.url}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.url, nil)
+	if err != nil {
+		return Down(err, details)
+	}
+
+	if hc.authorization != "" {
+		req.Header.Set("Authorization", hc.authorization)
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return Down(err, details)
+	}
+
+	_ = resp.Body.Close()
+
+	details["status"] = resp.StatusCode
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Down(fmt.Errorf("unexpected status %s", resp.Status), details)
+	}
+
+	return Up(details)
+
+}
+
+// * =========== *
+
+// Name returns the name of the component
+func (ec *EurekaChecker) Name() string {
+	return "eureka"
+}
+
+// * =========== *
+
+// Check reports UNKNOWN while the heartbeats fail instead of DOWN, so a Eureka outage does not make the instance unready
+// while it can still serve the clients that know it
+func (ec *EurekaChecker) Check(_ context.Context) Component {
+
+	status := ec.client.HeartbeatStatus()
+
+	details := map[string]interface{}{
+		"consecutiveFailures": status.ConsecutiveFailures,
+		"reregistrations":     status.Reregistrations,
+	}
+
+	if !status.LastSuccess.IsZero() {
+		details["lastSuccess"] = status.LastSuccess.Format(time.RFC3339)
+	}
+
+	if status.ConsecutiveFailures > 0 {
+		details["error"] = status.LastError
+		return Component{Status: StatusUnknown, Details: details}
+	}
+
+	return Up(details)
+
+}
+
+// * =========== *
+
+// Name returns the name of the component
+func (sc *staticChecker) Name() string {
+	return sc.name
+}
+
+// * =========== *
+
+// Check returns the component
+func (sc *staticChecker) Check(_ context.Context) Component {
+	return sc.component
+}
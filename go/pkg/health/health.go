@@ -0,0 +1,199 @@
+package health
+
+import (
+	"MicroserviceTemplate/config"
+	"context"
+	"sync"
+	"time"
+)
+
+// ? ==================== Constants ==================== ?
+
+// Statuses reported by the checkers, with the same names Spring Boot Actuator uses
+const (
+	StatusUp           = "UP"
+	StatusDown         = "DOWN"
+	StatusOutOfService = "OUT_OF_SERVICE"
+	StatusUnknown      = "UNKNOWN"
+)
+
+// defaultCheckTimeout bounds every check when management.health.timeout is not set
+const defaultCheckTimeout = 5 * time.Second
+
+// ? ==================== Variables ==================== ?
+
+// statusOrder is the severity of each status, the aggregate takes the most severe status of its components
+var statusOrder = map[string]int{
+	StatusDown:         0,
+	StatusOutOfService: 1,
+	StatusUp:           2,
+	StatusUnknown:      3,
+}
+
+// ? ==================== Interfaces ==================== ?
+
+type IChecker interface {
+	Name() string
+	Check(ctx context.Context) Component
+}
+
+// * =========== *
+
+type IHealth interface {
+	Health(ctx context.Context) Component
+	Liveness(ctx context.Context) Component
+	Readiness(ctx context.Context) Component
+}
+
+// ? ==================== Structs ==================== ?
+
+// Component is the health of a dependency or of the whole application in the Spring Boot Actuator format
+type Component struct {
+	Status     string                 `json:"status"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	Components map[string]Component   `json:"components,omitempty"`
+}
+
+// * =========== *
+
+// Health aggregates the result of every checker
+type Health struct {
+	checkers []IChecker
+	config   config.ManagementHealthConfig
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewHealth returns a new health aggregate of the checkers
+func NewHealth(checkers []IChecker, management config.ManagementConfig) IHealth {
+	return &Health{checkers, management.Health}
+}
+
+// * =========== *
+
+// Up returns a healthy component with the given details
+func Up(details map[string]interface{}) Component {
+	return Component{Status: StatusUp, Details: details}
+}
+
+// * =========== *
+
+// Down returns an unhealthy component with the error in its details
+func Down(err error, details map[string]interface{}) Component {
+
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	details["error"] = err.Error()
+
+	return Component{Status: StatusDown, Details: details}
+
+}
+
+// ? ==================== Methods ==================== ?
+
+// Health returns the aggregate health of every dependency
+func (h *Health) Health(ctx context.Context) Component {
+	return aggregate(ctx, h.checkers, h.config.Timeout)
+}
+
+// * =========== *
+
+// Liveness reports whether the process is running, it never depends on external services so the instance is not restarted because of them
+func (h *Health) Liveness(_ context.Context) Component {
+	return Component{Status: StatusUp}
+}
+
+// * =========== *
+
+// Readiness reports whether the instance can serve traffic, that is, whether its dependencies are healthy. The
+// components in management.health.readiness.exclude are left out, so the outage of a remote dependency shared by every
+// instance, such as the config server, does not take all of them out of service.
+func (h *Health) Readiness(ctx context.Context) Component {
+
+	checkers := make([]IChecker, 0, len(h.checkers))
+
+	for _, checker := range h.checkers {
+		if !h.isExcludedFromReadiness(checker.Name()) {
+			checkers = append(checkers, checker)
+		}
+	}
+
+	return aggregate(ctx, checkers, h.config.Timeout)
+
+}
+
+// * =========== *
+
+// isExcludedFromReadiness reports whether the component is left out of the readiness
+func (h *Health) isExcludedFromReadiness(name string) bool {
+
+	for _, excluded := range h.config.Readiness.Exclude {
+		if excluded == name {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// ? ==================== Functions ==================== ?
+
+// WithoutDetails returns the component with the status of its components but none of their details, for the callers
+// that are not authorized to see them
+func WithoutDetails(component Component) Component {
+
+	hidden := Component{Status: component.Status}
+
+	if len(component.Components) > 0 {
+		hidden.Components = make(map[string]Component, len(component.Components))
+		for name, nested := range component.Components {
+			hidden.Components[name] = WithoutDetails(nested)
+		}
+	}
+
+	return hidden
+
+}
+
+// * =========== *
+
+// aggregate runs every checker concurrently and takes the most severe status as the overall status
+func aggregate(ctx context.Context, checkers []IChecker, timeout time.Duration) Component {
+
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	results := make([]Component, len(checkers))
+
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func(i int, checker IChecker) {
+			defer wg.Done()
+			results[i] = checker.Check(ctx)
+		}(i, checker)
+	}
+	wg.Wait()
+
+	health := Component{Status: StatusUp}
+
+	if len(checkers) > 0 {
+		health.Components = make(map[string]Component, len(checkers))
+	}
+
+	for i, checker := range checkers {
+		health.Components[checker.Name()] = results[i]
+		if statusOrder[results[i].Status] < statusOrder[health.Status] {
+			health.Status = results[i].Status
+		}
+	}
+
+	return health
+
+}
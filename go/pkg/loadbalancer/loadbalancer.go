@@ -0,0 +1,320 @@
+package loadbalancer
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/registry"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ? ==================== Interfaces ==================== ?
+
+type ILoadBalancer interface {
+	http.RoundTripper
+	Choose(appName string) (registry.Instance, error)
+	Client() *http.Client
+}
+
+// ? ==================== Structs ==================== ?
+
+// LoadBalancer is the http.RoundTripper that sends the requests to lb://app-name/path and http://lb/app-name/path to
+// an instance of the application found in the service registry, like Spring Cloud LoadBalancer does. The idempotent requests
+// are retried on another instance, and the instances that fail are not chosen again for a while.
+type LoadBalancer struct {
+	registry         registry.IServiceRegistry
+	strategy         IStrategy
+	next             http.RoundTripper
+	maxAttempts      int
+	evictionDuration time.Duration
+
+	mu      sync.Mutex
+	evicted map[string]time.Time
+}
+
+// ? ==================== Constants ==================== ?
+
+const (
+	// Scheme is the scheme of the URLs resolved by the load balancer, lb://app-name/path
+	Scheme = "lb"
+
+	// Host is the host of the URLs resolved by the load balancer, http://lb/app-name/path
+	Host = "lb"
+)
+
+// ? ==================== Variables ==================== ?
+
+// ErrNoInstances is returned when the application has no instance available
+var ErrNoInstances = errors.New("no instance available")
+
+// ? ==================== Constructors ==================== ?
+
+// NewLoadBalancer returns a new load balancer of the instances found in the service registry
+func NewLoadBalancer(loadBalancer config.LoadBalancerConfig, serviceRegistry registry.IServiceRegistry) ILoadBalancer {
+	return NewRoundTripper(
+		serviceRegistry,
+		NewStrategy(loadBalancer.Strategy, loadBalancer.Zone),
+		loadBalancer.MaxAttempts,
+		loadBalancer.EvictionDuration,
+		http.DefaultTransport,
+	)
+}
+
+// * =========== *
+
+// NewRoundTripper returns a new load balancer that sends the requests through the next round tripper
+func NewRoundTripper(serviceRegistry registry.IServiceRegistry, strategy IStrategy, maxAttempts int, evictionDuration time.Duration, next http.RoundTripper) *LoadBalancer {
+
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return &LoadBalancer{
+		registry:         serviceRegistry,
+		strategy:         strategy,
+		next:             next,
+		maxAttempts:      maxAttempts,
+		evictionDuration: evictionDuration,
+		evicted:          map[string]time.Time{},
+	}
+
+}
+
+// ? ==================== Methods ==================== ?
+
+// Client returns an HTTP client that resolves the load balanced URLs
+func (lb *LoadBalancer) Client() *http.Client {
+	return &http.Client{Transport: lb}
+}
+
+// * =========== *
+
+// Choose returns an available instance of the application
+func (lb *LoadBalancer) Choose(appName string) (registry.Instance, error) {
+	instance, _, err := lb.choose(appName, nil)
+	return instance, err
+}
+
+// * =========== *
+
+// RoundTrip sends the request to an instance of the application, the requests to other URLs are sent as they are. Like
+// every RoundTripper it closes the body of the request, even when it fails before sending it.
+func (lb *LoadBalancer) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	appName, path, ok := resolve(req.URL)
+	if !ok {
+		return lb.next.RoundTrip(req)
+	}
+
+	attempts := 1
+	if isRetryable(req) {
+		attempts = lb.maxAttempts
+	}
+
+	tried := map[string]bool{}
+
+	for attempt := 1; ; attempt++ {
+
+		instance, available, err := lb.choose(appName, tried)
+		if err != nil {
+			closeBody(req)
+			return nil, err
+		}
+
+		tried[instance.ID] = true
+
+		outReq, err := rewrite(req, instance, path, attempt > 1)
+		if err != nil {
+			closeBody(req)
+			return nil, err
+		}
+
+		resp, err := lb.next.RoundTrip(outReq)
+		if err == nil && !isRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		lb.evict(instance)
+
+		if attempt >= attempts || len(tried) >= available {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		log.Printf("request to %s instance %s failed, retrying on another instance", appName, instance.ID)
+
+	}
+
+}
+
+// * =========== *
+
+// choose returns an instance of the application that has not been tried yet, preferring the ones that have not failed
+// recently, and the number of instances of the application
+func (lb *LoadBalancer) choose(appName string, tried map[string]bool) (registry.Instance, int, error) {
+
+	instances, err := lb.registry.GetInstances(appName)
+	if err != nil {
+		return registry.Instance{}, 0, fmt.Errorf("couldn't find the instances of %s: %w", appName, err)
+	}
+
+	if len(instances) == 0 {
+		return registry.Instance{}, 0, fmt.Errorf("%w for %s", ErrNoInstances, appName)
+	}
+
+	var untried, healthy []registry.Instance
+
+	lb.mu.Lock()
+	now := time.Now()
+	for _, instance := range instances {
+
+		if tried[instance.ID] {
+			continue
+		}
+
+		untried = append(untried, instance)
+
+		if until, ok := lb.evicted[instance.ID]; ok && now.Before(until) {
+			continue
+		}
+
+		healthy = append(healthy, instance)
+
+	}
+	lb.mu.Unlock()
+
+	// When every instance failed recently one of them is tried anyway, it may have recovered
+	if len(healthy) > 0 {
+		return lb.strategy.Choose(appName, healthy), len(instances), nil
+	}
+
+	if len(untried) > 0 {
+		return lb.strategy.Choose(appName, untried), len(instances), nil
+	}
+
+	return registry.Instance{}, len(instances), fmt.Errorf("%w for %s", ErrNoInstances, appName)
+
+}
+
+// * =========== *
+
+// evict stops choosing the instance for the eviction duration
+func (lb *LoadBalancer) evict(instance registry.Instance) {
+
+	if lb.evictionDuration <= 0 {
+		return
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	now := time.Now()
+	lb.evicted[instance.ID] = now.Add(lb.evictionDuration)
+
+	for id, until := range lb.evicted {
+		if now.After(until) {
+			delete(lb.evicted, id)
+		}
+	}
+
+}
+
+// ? ==================== Functions ==================== ?
+
+// resolve returns the application and the path of the load balanced URL
+func resolve(u *url.URL) (string, string, bool) {
+
+	if u.Scheme == Scheme {
+		return u.Hostname(), u.EscapedPath(), u.Hostname() != ""
+	}
+
+	if u.Host == Host {
+		appName, path, _ := strings.Cut(strings.TrimPrefix(u.EscapedPath(), "/"), "/")
+		return appName, "/" + path, appName != ""
+	}
+
+	return "", "", false
+
+}
+
+// * =========== *
+
+// rewrite returns a copy of the request sent to the instance. The body of a retried request is read again.
+func rewrite(req *http.Request, instance registry.Instance, path string, retry bool) (*http.Request, error) {
+
+	outReq := req.Clone(req.Context())
+
+	scheme := "http"
+	if req.URL.Scheme == "https" || (req.URL.Scheme == Scheme && instance.Secure) {
+		scheme = "https"
+	}
+
+	outReq.URL.Scheme = scheme
+	outReq.URL.Host = net.JoinHostPort(instance.Host, strconv.Itoa(instance.Port))
+	outReq.URL.Path = ""
+	outReq.URL.RawPath = ""
+
+	if parsed, err := url.Parse(path); err == nil {
+		outReq.URL.Path, outReq.URL.RawPath = parsed.Path, parsed.RawPath
+	}
+
+	outReq.Host = ""
+
+	if retry && req.Body != nil && req.Body != http.NoBody {
+
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		outReq.Body = body
+
+	}
+
+	return outReq, nil
+
+}
+
+// * =========== *
+
+// closeBody closes the body of the request when it has one
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+// * =========== *
+
+// isRetryable reports whether the request can be sent again to another instance: it must be idempotent and its body,
+// if any, must be readable again
+func isRetryable(req *http.Request) bool {
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+}
+
+// * =========== *
+
+// isRetryableStatus reports whether the status means the instance could not handle the request
+func isRetryableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
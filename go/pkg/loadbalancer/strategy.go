@@ -0,0 +1,130 @@
+package loadbalancer
+
+import (
+	"MicroserviceTemplate/pkg/registry"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ? ==================== Interfaces ==================== ?
+
+// IStrategy chooses the instance a request is sent to among the available instances of an application
+type IStrategy interface {
+	Choose(appName string, instances []registry.Instance) registry.Instance
+}
+
+// ? ==================== Structs ==================== ?
+
+// RoundRobinStrategy chooses the instances of each application in turn
+type RoundRobinStrategy struct {
+	counters sync.Map
+}
+
+// * =========== *
+
+// RandomStrategy chooses any instance
+type RandomStrategy struct {
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// * =========== *
+
+// ZonePreferenceStrategy chooses the instances of the zone of the application in turn, and the instances of the other
+// zones when there is none in its zone
+type ZonePreferenceStrategy struct {
+	zone       string
+	roundRobin *RoundRobinStrategy
+}
+
+// ? ==================== Constants ==================== ?
+
+const (
+	StrategyRoundRobin     = "round-robin"
+	StrategyRandom         = "random"
+	StrategyZonePreference = "zone-preference"
+)
+
+// ? ==================== Constructors ==================== ?
+
+// NewStrategy returns the strategy of the name, round robin when the name is unknown
+func NewStrategy(name string, zone string) IStrategy {
+
+	switch name {
+	case StrategyRandom:
+		return NewRandomStrategy()
+	case StrategyZonePreference:
+		return NewZonePreferenceStrategy(zone)
+	default:
+		return NewRoundRobinStrategy()
+	}
+
+}
+
+// * =========== *
+
+// NewRoundRobinStrategy returns a new round robin strategy
+func NewRoundRobinStrategy() *RoundRobinStrategy {
+	return &RoundRobinStrategy{}
+}
+
+// * =========== *
+
+// NewRandomStrategy returns a new random strategy
+func NewRandomStrategy() *RandomStrategy {
+	return &RandomStrategy{random: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// * =========== *
+
+// NewZonePreferenceStrategy returns a new strategy that prefers the instances of the zone
+func NewZonePreferenceStrategy(zone string) *ZonePreferenceStrategy {
+	return &ZonePreferenceStrategy{zone, NewRoundRobinStrategy()}
+}
+
+// ? ==================== Methods ==================== ?
+
+// Choose returns the next instance of the application
+func (rr *RoundRobinStrategy) Choose(appName string, instances []registry.Instance) registry.Instance {
+
+	counter, _ := rr.counters.LoadOrStore(strings.ToUpper(appName), new(uint64))
+	next := atomic.AddUint64(counter.(*uint64), 1) - 1
+
+	return instances[next%uint64(len(instances))]
+
+}
+
+// * =========== *
+
+// Choose returns any instance of the application
+func (rs *RandomStrategy) Choose(_ string, instances []registry.Instance) registry.Instance {
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return instances[rs.random.Intn(len(instances))]
+
+}
+
+// * =========== *
+
+// Choose returns the next instance of the zone, or of any zone when there is none in the zone
+func (zp *ZonePreferenceStrategy) Choose(appName string, instances []registry.Instance) registry.Instance {
+
+	var sameZone []registry.Instance
+	for _, instance := range instances {
+		if strings.EqualFold(instance.Metadata["zone"], zp.zone) {
+			sameZone = append(sameZone, instance)
+		}
+	}
+
+	if len(sameZone) == 0 {
+		return zp.roundRobin.Choose(appName, instances)
+	}
+
+	return zp.roundRobin.Choose(appName, sameZone)
+
+}
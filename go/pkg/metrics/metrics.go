@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"strconv"
+	"time"
+)
+
+// ? ==================== Constants ==================== ?
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// * =========== *
+
+// The sources the configuration of the application can be loaded from
+const (
+	ConfigSourceServer = "config-server"
+	ConfigSourceCache  = "cache"
+	ConfigSourceLocal  = "local"
+)
+
+// ? ==================== Collectors ==================== ?
+
+var (
+	// httpRequests has the same name and labels as the Spring Boot metric, so the same dashboards work for both
+	httpRequests = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_requests_seconds",
+		Help:    "Duration of the HTTP requests by route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "uri", "status", "outcome"})
+
+	mongoOperations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_operation_seconds",
+		Help:    "Duration of the MongoDB operations by repository method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	mongoErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_operation_errors_total",
+		Help: "Failed MongoDB operations by repository method and error kind.",
+	}, []string{"method", "kind"})
+
+	eurekaHeartbeats = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eureka_heartbeats_total",
+		Help: "Heartbeats sent to the Eureka server by outcome.",
+	}, []string{"outcome"})
+
+	eurekaHeartbeatFailures = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "eureka_heartbeat_consecutive_failures",
+		Help: "Heartbeats to the Eureka server that failed in a row.",
+	})
+
+	eurekaReregistrations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eureka_reregistrations_total",
+		Help: "Registrations of the instance again after the Eureka server forgot it, by outcome.",
+	}, []string{"outcome"})
+
+	configRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_refresh_total",
+		Help: "Loads of the configuration from the config server by outcome.",
+	}, []string{"outcome"})
+
+	configSource = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "config_source",
+		Help: "Source the running configuration was loaded from, the active source is 1.",
+	}, []string{"source"})
+)
+
+// ? ==================== Functions ==================== ?
+
+// Handler returns the handler that exposes the metrics in the Prometheus text format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// * =========== *
+
+// ObserveMongo records the duration of a repository method and, when it failed, the kind of error
+func ObserveMongo(method string, start time.Time, errorKind string) {
+
+	mongoOperations.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+	if errorKind != "" {
+		mongoErrors.WithLabelValues(method, errorKind).Inc()
+	}
+
+}
+
+// * =========== *
+
+// RecordHeartbeat counts a heartbeat sent to Eureka
+func RecordHeartbeat(err error) {
+	eurekaHeartbeats.WithLabelValues(outcome(err)).Inc()
+}
+
+// * =========== *
+
+// RecordHeartbeatFailures sets the number of heartbeats to Eureka that failed in a row
+func RecordHeartbeatFailures(failures int) {
+	eurekaHeartbeatFailures.Set(float64(failures))
+}
+
+// * =========== *
+
+// RecordReregistration counts a registration of the instance again after Eureka forgot it
+func RecordReregistration(err error) {
+	eurekaReregistrations.WithLabelValues(outcome(err)).Inc()
+}
+
+// * =========== *
+
+// RecordConfigRefresh counts a load of the configuration from the config server
+func RecordConfigRefresh(err error) {
+	configRefreshes.WithLabelValues(outcome(err)).Inc()
+}
+
+// * =========== *
+
+// RecordConfigSource marks the source the running configuration was loaded from
+func RecordConfigSource(source string) {
+
+	for _, s := range []string{ConfigSourceServer, ConfigSourceCache, ConfigSourceLocal} {
+		configSource.WithLabelValues(s).Set(0)
+	}
+
+	configSource.WithLabelValues(source).Set(1)
+
+}
+
+// * =========== *
+
+// outcome returns the outcome label for the error
+func outcome(err error) string {
+
+	if err != nil {
+		return OutcomeFailure
+	}
+
+	return OutcomeSuccess
+
+}
+
+// ? ==================== Middlewares ==================== ?
+
+// Middleware records the duration of every request by its route template, so /products/{id} is a single series
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		start := time.Now()
+
+		c.Next()
+
+		uri := c.FullPath()
+		if uri == "" {
+			uri = "NOT_FOUND"
+		}
+
+		status := c.Writer.Status()
+
+		httpRequests.WithLabelValues(
+			c.Request.Method,
+			uri,
+			strconv.Itoa(status),
+			httpOutcome(status),
+		).Observe(time.Since(start).Seconds())
+
+	}
+}
+
+// * =========== *
+
+// httpOutcome classifies the status code the same way Spring Boot does
+func httpOutcome(status int) string {
+
+	switch {
+	case status < 200:
+		return "INFORMATIONAL"
+	case status < 300:
+		return "SUCCESS"
+	case status < 400:
+		return "REDIRECTION"
+	case status < 500:
+		return "CLIENT_ERROR"
+	default:
+		return "SERVER_ERROR"
+	}
+
+}
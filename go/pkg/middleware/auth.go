@@ -1,14 +1,19 @@
 package middleware
 
 import (
+	"MicroserviceTemplate/config"
 	"MicroserviceTemplate/pkg/web"
 	"context"
 	"crypto/tls"
+	"fmt"
 	"github.com/coreos/go-oidc"
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
+	"log"
 	"net/http"
+	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -35,8 +40,111 @@ type clientRoles struct {
 	Roles []string `json:"roles,omitempty"`
 }
 
+// * =========== *
+
+// realmVerifierEntry is the token verifier of a Keycloak realm and the context that carries its HTTP client
+type realmVerifierEntry struct {
+	verifier *oidc.IDTokenVerifier
+	ctx      context.Context
+}
+
+// ? ==================== Constants ==================== ?
+
+// ClaimsKey is the key of the gin context the claims of the token verified by IsAuthorizedJWT are kept under
+const ClaimsKey = "claims"
+
+// ? ==================== Variables ==================== ?
+
+// excludedPaths holds the paths excluded from authorization by configuration, they can change at runtime
+var excludedPaths atomic.Value
+
+// verifiers holds the token verifier of every Keycloak realm the middleware has verified tokens of
+var verifiers = struct {
+	mu      sync.Mutex
+	byRealm map[string]realmVerifierEntry
+}{byRealm: map[string]realmVerifierEntry{}}
+
 // ? ==================== Functions ==================== ?
 
+// SetExcludedPaths replaces the paths excluded from authorization besides the ones given to the middleware. The blank
+// paths and the ones that would exclude every route are dropped.
+func SetExcludedPaths(paths ...string) {
+
+	var kept []string
+
+	for _, path := range paths {
+
+		if excludedPrefix(path) == "" {
+			log.Printf("ignoring the path %q excluded from authorization, it would exclude every route", path)
+			continue
+		}
+
+		kept = append(kept, strings.TrimSpace(path))
+
+	}
+
+	excludedPaths.Store(kept)
+
+}
+
+// * =========== *
+
+// ExcludedPaths returns the paths excluded from authorization by configuration
+func ExcludedPaths() []string {
+	paths, _ := excludedPaths.Load().([]string)
+	return paths
+}
+
+// * =========== *
+
+// isExcludedPath reports whether the request path is any of the excluded paths or is nested under it. The paths are
+// compared by whole segments, so /health/** excludes /health/liveness but not /healthz or /products/health.
+func isExcludedPath(requestPath string, paths []string) bool {
+
+	requestPath = path.Clean("/" + requestPath)
+
+	for _, excluded := range paths {
+
+		prefix := excludedPrefix(excluded)
+		if prefix == "" {
+			continue
+		}
+
+		if requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/") {
+			return true
+		}
+
+	}
+
+	return false
+
+}
+
+// * =========== *
+
+// excludedPrefix returns the path an excluded path covers without its /** or /*any wildcard, it is empty when the
+// path covers every route
+func excludedPrefix(excluded string) string {
+
+	prefix := strings.TrimSpace(excluded)
+	prefix = strings.TrimSuffix(prefix, "/**")
+	prefix = strings.TrimSuffix(prefix, "/*any")
+
+	if prefix == "" {
+		return ""
+	}
+
+	prefix = path.Clean("/" + prefix)
+	if prefix == "/" {
+		return ""
+	}
+
+	return prefix
+
+}
+
+// * =========== *
+
 // AuthorizationFailed returns an authorization error in case the token is not valid for gin
 func authorizationFailed(message string, c *gin.Context) {
 
@@ -50,93 +158,189 @@ func authorizationFailed(message string, c *gin.Context) {
 
 }
 
-// ? ==================== Middlewares ==================== ?
+// * =========== *
 
-// IsAuthorizedJWT is the middleware that is in charge of validating the JWT token and verifying that the user has the necessary permissions to access the route
-func IsAuthorizedJWT(excludePaths ...string) gin.HandlerFunc {
+// forbidden returns an authorization error when the token is valid but lacks the role the route requires
+func forbidden(message string, c *gin.Context) {
 
-	Realm := viper.GetString("keycloak.realm")
-	RealmConfigURL := viper.GetString("keycloak.url") + "/realms/" + Realm
+	data := web.ErrorResponse{
+		Code:    "forbidden",
+		Status:  http.StatusForbidden,
+		Message: message,
+	}
 
-	return func(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"response": data})
 
-		for _, path := range excludePaths {
+}
 
-			mainRoute := ""
+// * =========== *
 
-			if strings.Contains(path, "/**") {
-				mainRoute = strings.Split(path, "/**")[0]
-			} else if strings.Contains(path, "/*any") {
-				mainRoute = strings.Split(path, "/*any")[0]
-			} else {
-				mainRoute = path
-			}
+// verifyToken validates the bearer token of the request with Keycloak and returns its claims
+func verifyToken(keycloak config.KeycloakConfig, c *gin.Context) (*Claims, error) {
 
-			if strings.Contains(c.Request.URL.Path, mainRoute) {
-				c.Next()
-				return
-			}
+	// The header token is obtained by means of the Authorization key of type Bearer.
+	rawAccessToken := strings.Replace(c.GetHeader("Authorization"), "Bearer ", "", 1)
 
-		}
+	verifier, ctx, err := realmVerifier(keycloak)
+	if err != nil {
+		return nil, fmt.Errorf("authorization failed while getting the provider: %w", err)
+	}
 
-		// The header token is obtained by means of the Authorization key of type Bearer.
-		rawAccessToken := strings.Replace(c.GetHeader("Authorization"), "Bearer ", "", 1)
+	// The integrity of the token is validated using the authorization provider (Keycloak) and the OpenId Connector configuration created earlier.
+	idToken, err := verifier.Verify(ctx, rawAccessToken)
 
-		// The transport is responsible for validating the token using the certificate authority's certificate (TLS for HTTPS).
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
+	if err != nil {
+		return nil, fmt.Errorf("authorization failed while verifying the token: %w", err)
+	}
 
-		// An HTTP client is created with the previously created transport
-		client := &http.Client{
-			Timeout:   time.Duration(6000) * time.Second,
-			Transport: tr,
-		}
+	// If the token is valid, a Claims object is created to map the contents of the token.
+	var IDTokenClaims Claims
+	if err := idToken.Claims(&IDTokenClaims); err != nil {
+		return nil, fmt.Errorf("claims : %w", err)
+	}
 
-		// An OpenId Connector context is created with the previously created HTTP client
-		ctx := oidc.ClientContext(context.Background(), client)
+	return &IDTokenClaims, nil
 
-		RealmUrl := strings.ReplaceAll(RealmConfigURL, "\\", "")
+}
 
-		// An OpenId Connector provider is created with the authorization provider (Keycloak) and the previously created context
-		provider, err := oidc.NewProvider(ctx, RealmUrl)
-		if err != nil {
-			authorizationFailed("authorization failed while getting the provider: "+err.Error(), c) // An authorization error is returned in case the supplier is invalid.
-			return
+// * =========== *
+
+// realmVerifier returns the token verifier of the Keycloak realm with the context its keys are fetched with. It is
+// built on the first call and reused, so the discovery document and the keys of the realm are not fetched for every
+// request. A provider that cannot be reached is not kept, the next call tries again.
+func realmVerifier(keycloak config.KeycloakConfig) (*oidc.IDTokenVerifier, context.Context, error) {
+
+	RealmUrl := strings.ReplaceAll(keycloak.URL+"/realms/"+keycloak.Realm, "\\", "")
+
+	verifiers.mu.Lock()
+	defer verifiers.mu.Unlock()
+
+	if cached, ok := verifiers.byRealm[RealmUrl]; ok {
+		return cached.verifier, cached.ctx, nil
+	}
+
+	// The transport is responsible for validating the token using the certificate authority's certificate (TLS for HTTPS).
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+
+	// An HTTP client is created with the previously created transport
+	client := &http.Client{
+		Timeout:   time.Duration(6000) * time.Second,
+		Transport: tr,
+	}
+
+	// An OpenId Connector context is created with the previously created HTTP client
+	ctx := oidc.ClientContext(context.Background(), client)
+
+	// An OpenId Connector provider is created with the authorization provider (Keycloak) and the previously created context
+	provider, err := oidc.NewProvider(ctx, RealmUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// An OpenId Connector configuration object is created based on the ClientID
+	oidcConfig := &oidc.Config{
+		ClientID: "account",
+	}
+
+	verifier := provider.Verifier(oidcConfig)
+	verifiers.byRealm[RealmUrl] = realmVerifierEntry{verifier, ctx}
+
+	return verifier, ctx, nil
+
+}
+
+// * =========== *
+
+// hasAnyRole reports whether the claims contain at least one realm role
+func hasAnyRole(claims *Claims) bool {
+
+	// We obtain the roles that are associated to the client in our case the roles are located in {"realm_access": {"roles": ["EDITOR", "USER"]}}}
+	for _, b := range claims.RealmAccess.Roles {
+		// if the token contains the indicated role, you are allowed access.
+		if b != "" {
+			return true
 		}
+	}
+
+	return false
+
+}
+
+// * =========== *
 
-		// An OpenId Connector configuration object is created based on the ClientID
-		oidcConfig := &oidc.Config{
-			ClientID: "account",
+// hasRole reports whether the claims contain the realm role
+func hasRole(claims *Claims, role string) bool {
+
+	for _, b := range claims.RealmAccess.Roles {
+		if b == role {
+			return true
 		}
+	}
 
-		// The integrity of the token is validated using the authorization provider (Keycloak) and the OpenId Connector configuration created earlier.
+	return false
 
-		verifier := provider.Verifier(oidcConfig)
-		idToken, err := verifier.Verify(ctx, rawAccessToken)
+}
 
-		if err != nil {
-			authorizationFailed("authorization failed while verifying the token: "+err.Error(), c) // An authorization error is returned in case the token is invalid.
+// * =========== *
+
+// IsAuthorized reports whether the request carries a valid token with a realm role, for the public endpoints that show
+// more to the authorized callers. The token is not verified when the request has none.
+func IsAuthorized(keycloak config.KeycloakConfig, c *gin.Context) bool {
+
+	if c.GetHeader("Authorization") == "" {
+		return false
+	}
+
+	claims, err := verifyToken(keycloak, c)
+
+	return err == nil && hasAnyRole(claims)
+
+}
+
+// ? ==================== Middlewares ==================== ?
+
+// IsAuthorizedJWT is the middleware that is in charge of validating the JWT token and verifying that the user has the necessary permissions to access the route
+func IsAuthorizedJWT(keycloak config.KeycloakConfig, excludePaths ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		if isExcludedPath(c.Request.URL.Path, excludePaths) || isExcludedPath(c.Request.URL.Path, ExcludedPaths()) {
+			c.Next()
 			return
 		}
 
-		// If the token is valid, a Claims object is created to map the contents of the token.
-		var IDTokenClaims Claims
-		if err := idToken.Claims(&IDTokenClaims); err != nil {
-			authorizationFailed("claims : "+err.Error(), c) // An authorization error is returned in case the token is invalid.
+		claims, err := verifyToken(keycloak, c)
+		if err != nil {
+			authorizationFailed(err.Error(), c) // An authorization error is returned in case the token is invalid.
 			return
 		}
 
-		// We obtain the roles that are associated to the client in our case the roles are located in {"realm_access": {"roles": ["EDITOR", "USER"]}}}
-		userAccessRoles := IDTokenClaims.RealmAccess.Roles
-		for _, b := range userAccessRoles {
-			// if the token contains the indicated role, you are allowed access.
-			if b != "" {
-				c.Next()
-				return
-			}
+		if hasAnyRole(claims) {
+			c.Set(ClaimsKey, claims)
+			c.Next()
+			return
 		}
 
 		authorizationFailed("user not allowed to access this api", c) // An authorization error is returned in case the token is invalid.
 	}
 }
+
+// * =========== *
+
+// HasRole is the middleware that only lets through the requests whose token, verified by IsAuthorizedJWT, has the realm
+// role. It goes after IsAuthorizedJWT, the requests of the excluded paths carry no claims and are rejected.
+func HasRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		claims, _ := c.Get(ClaimsKey)
+
+		if claims, ok := claims.(*Claims); ok && role != "" && hasRole(claims, role) {
+			c.Next()
+			return
+		}
+
+		forbidden("the "+role+" role is required to access this api", c)
+
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"MicroserviceTemplate/pkg/web"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
+// ? ==================== Interfaces ==================== ?
+
+// statusCoder is implemented by the errors that know the HTTP status they should be reported with
+type statusCoder interface {
+	StatusCode() int
+}
+
+// * =========== *
+
+// errorCoder is implemented by the errors that carry a machine-readable code
+type errorCoder interface {
+	ErrorCode() string
+}
+
+// * =========== *
+
+// fieldErrorer is implemented by the errors that list the fields that broke a validation rule
+type fieldErrorer interface {
+	FieldErrors() []web.FieldError
+}
+
+// ? ==================== Middlewares ==================== ?
+
+// ProblemDetails is the middleware that translates the last error added to the gin context into an RFC 7807 response
+func ProblemDetails() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+
+		problem := web.ProblemDetails{
+			Type:     "about:blank",
+			Status:   http.StatusInternalServerError,
+			Instance: c.Request.URL.Path,
+		}
+
+		// Only the errors that know their status are meant to be read by clients
+		var coder statusCoder
+		if errors.As(err, &coder) {
+			problem.Status = coder.StatusCode()
+			problem.Detail = err.Error()
+		}
+
+		problem.Title = http.StatusText(problem.Status)
+
+		var code errorCoder
+		if errors.As(err, &code) {
+			problem.Code = code.ErrorCode()
+		}
+
+		var fields fieldErrorer
+		if errors.As(err, &fields) {
+			problem.Errors = fields.FieldErrors()
+		}
+
+		// The cause of server errors is logged since it is not exposed to the client
+		if problem.Status >= http.StatusInternalServerError {
+			log.Printf("%s %s failed: %v, cause: %v", c.Request.Method, c.Request.URL.Path, err, errors.Unwrap(err))
+		}
+
+		web.ProblemResponseBody(c, problem)
+
+	}
+}
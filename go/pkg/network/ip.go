@@ -0,0 +1,49 @@
+package network
+
+import (
+	"net"
+)
+
+// ? ==================== Functions ==================== ?
+
+// OutboundIP returns the IP address of the interface the outbound traffic leaves through, or of the first interface up
+// that is not a loopback. Dialing UDP sends no packet, it only chooses the route.
+func OutboundIP() string {
+
+	if conn, err := net.Dial("udp", "8.8.8.8:80"); err == nil {
+		defer func(conn net.Conn) {
+			_ = conn.Close()
+		}(conn)
+
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && !addr.IP.IsUnspecified() {
+			return addr.IP.String()
+		}
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "127.0.0.1"
+	}
+
+	for _, iface := range interfaces {
+
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				return ipNet.IP.String()
+			}
+		}
+
+	}
+
+	return "127.0.0.1"
+
+}
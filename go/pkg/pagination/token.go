@@ -0,0 +1,99 @@
+package pagination
+
+import (
+	"MicroserviceTemplate/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ? ==================== Errors ==================== ?
+
+// ErrInvalidToken is returned when a continuation token is malformed or its signature does not match
+var ErrInvalidToken = errors.New("invalid continuation token")
+
+// ? ==================== Interfaces ==================== ?
+
+type ITokenCodec interface {
+	Encode(payload interface{}) (string, error)
+	Decode(token string, payload interface{}) error
+}
+
+// ? ==================== Structs ==================== ?
+
+// TokenCodec signs continuation tokens with HMAC-SHA256 so clients cannot forge or tamper with them
+type TokenCodec struct {
+	key []byte
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewTokenCodec returns a new continuation token codec signing with pagination.token-secret. Every replica must share
+// the secret, so the tokens issued by one of them, or before a restart, are accepted by the others.
+func NewTokenCodec(pagination config.PaginationConfig) ITokenCodec {
+	return &TokenCodec{[]byte(pagination.TokenSecret)}
+}
+
+// ? ==================== Methods ==================== ?
+
+// Encode serializes the payload and signs it, returning an opaque URL-safe token
+func (tc *TokenCodec) Encode(payload interface{}) (string, error) {
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	encodedData := base64.RawURLEncoding.EncodeToString(data)
+	signature := base64.RawURLEncoding.EncodeToString(tc.sign(data))
+
+	return encodedData + "." + signature, nil
+
+}
+
+// * =========== *
+
+// Decode verifies the token signature and deserializes its payload
+func (tc *TokenCodec) Decode(token string, payload interface{}) error {
+
+	encodedData, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return ErrInvalidToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encodedData)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	if !hmac.Equal(signature, tc.sign(data)) {
+		return ErrInvalidToken
+	}
+
+	if err := json.Unmarshal(data, payload); err != nil {
+		return ErrInvalidToken
+	}
+
+	return nil
+
+}
+
+// * =========== *
+
+// sign returns the HMAC of the data using the key of the codec
+func (tc *TokenCodec) sign(data []byte) []byte {
+
+	mac := hmac.New(sha256.New, tc.key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+
+}
@@ -0,0 +1,422 @@
+package registry
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/network"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ? ==================== Structs ==================== ?
+
+// ConsulRegistry registers the instance in the local Consul agent with a TTL check, updated by heartbeats sent every
+// half TTL, and finds the instances whose checks are passing
+type ConsulRegistry struct {
+	url             string
+	token           string
+	host            string
+	metadata        map[string]string
+	ttl             time.Duration
+	deregisterAfter time.Duration
+	client          *http.Client
+
+	mu             sync.Mutex
+	loops          map[string]*heartbeatLoop
+	statusProvider StatusProvider
+}
+
+// * =========== *
+
+// heartbeatLoop is the goroutine that keeps an instance alive, stop ends it and done is closed once it has returned
+type heartbeatLoop struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// * =========== *
+
+// consulStatusError is an answer of the Consul agent with a status other than 2xx
+type consulStatusError struct {
+	method     string
+	path       string
+	statusCode int
+}
+
+// * =========== *
+
+// consulService is the body of PUT /v1/agent/service/register
+type consulService struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   *consulCheck      `json:"Check,omitempty"`
+}
+
+// * =========== *
+
+type consulCheck struct {
+	CheckID                        string `json:"CheckID"`
+	TTL                            string `json:"TTL"`
+	Status                         string `json:"Status"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter,omitempty"`
+}
+
+// * =========== *
+
+// consulServiceEntry is an item of the body of GET /v1/health/service/:service
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Service string            `json:"Service"`
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+}
+
+// ? ==================== Types ==================== ?
+
+// StatusProvider returns the status the instance reports on its own, such as the aggregate of its health
+type StatusProvider func(ctx context.Context) string
+
+// ? ==================== Constants ==================== ?
+
+// metadataSecure is the metadata of the instances served over TLS, the same one Spring Cloud Consul uses
+const metadataSecure = "secure"
+
+// ? ==================== Constructors ==================== ?
+
+// NewConsulRegistry returns a new registry backed by the Consul agent
+func NewConsulRegistry(consul config.ConsulConfig, server config.ServerConfig) *ConsulRegistry {
+
+	discovery := consul.Discovery
+
+	ipAddress := discovery.IPAddress
+	if ipAddress == "" {
+		ipAddress = network.OutboundIP()
+	}
+
+	host := discovery.Hostname
+	if host == "" {
+		host = server.Hostname
+	}
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	if host == "" || discovery.PreferIPAddress {
+		host = ipAddress
+	}
+
+	metadata := map[string]string{metadataSecure: strconv.FormatBool(server.SSL.Enabled)}
+	for key, value := range discovery.Metadata {
+		metadata[key] = value
+	}
+
+	return &ConsulRegistry{
+		url:             strings.TrimSuffix(consul.URL, "/"),
+		token:           consul.Token,
+		host:            host,
+		metadata:        metadata,
+		ttl:             discovery.HealthCheckTTL,
+		deregisterAfter: discovery.DeregisterCriticalServiceAfter,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		loops:           map[string]*heartbeatLoop{},
+	}
+
+}
+
+// ? ==================== Methods ==================== ?
+
+// SetStatusProvider makes the TTL check of the instance follow the provider: it is checked before every heartbeat, the
+// check passes while the status is UP and warns otherwise, which leaves the instance out of the passing instances
+// without Consul deregistering it
+func (cr *ConsulRegistry) SetStatusProvider(provider StatusProvider) {
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.statusProvider = provider
+
+}
+
+// * =========== *
+
+// Register registers the instance and passes its TTL check until it is deregistered. A Consul agent that cannot be
+// reached does not stop the application, the instance is registered again once the agent is back.
+func (cr *ConsulRegistry) Register(instance Instance) error {
+
+	err := cr.register(instance)
+	if err != nil {
+		log.Printf("couldn't register the instance on the Consul agent, the heartbeats will retry: %s", err.Error())
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if _, ok := cr.loops[instance.ID]; !ok {
+		loop := &heartbeatLoop{make(chan struct{}), make(chan struct{})}
+		cr.loops[instance.ID] = loop
+		go cr.keepAlive(instance, loop)
+	}
+
+	return err
+
+}
+
+// * =========== *
+
+// Deregister stops the heartbeats and removes the instance from the Consul agent. It waits for the heartbeat in flight
+// first, so it cannot register the instance again after the deregistration.
+func (cr *ConsulRegistry) Deregister(instance Instance) error {
+
+	cr.mu.Lock()
+	loop, ok := cr.loops[instance.ID]
+	delete(cr.loops, instance.ID)
+	cr.mu.Unlock()
+
+	if ok {
+		close(loop.stop)
+		<-loop.done
+	}
+
+	return cr.send(http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(instance.ID), nil, nil)
+
+}
+
+// * =========== *
+
+// Heartbeat passes the TTL check of the instance, or makes it warn when the status provider does not report UP
+func (cr *ConsulRegistry) Heartbeat(instance Instance) error {
+
+	path := "/v1/agent/check/pass/" + url.PathEscape(checkID(instance.ID))
+
+	if status := cr.status(); status != StatusUp {
+		path = "/v1/agent/check/warn/" + url.PathEscape(checkID(instance.ID)) + "?note=" + url.QueryEscape("the instance is "+status)
+	}
+
+	err := cr.send(http.MethodPut, path, nil, nil)
+
+	var statusError *consulStatusError
+	if errors.As(err, &statusError) && statusError.statusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotRegistered, instance.ID)
+	}
+
+	return err
+
+}
+
+// * =========== *
+
+// GetInstances returns the instances of the application whose checks are passing
+func (cr *ConsulRegistry) GetInstances(appName string) ([]Instance, error) {
+
+	var entries []consulServiceEntry
+
+	err := cr.send(http.MethodGet, "/v1/health/service/"+url.PathEscape(serviceName(appName))+"?passing=true", nil, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]Instance, 0, len(entries))
+
+	for _, entry := range entries {
+
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+
+		instances = append(instances, Instance{
+			ID:       entry.Service.ID,
+			AppName:  entry.Service.Service,
+			Host:     host,
+			Port:     entry.Service.Port,
+			Secure:   entry.Service.Meta[metadataSecure] == "true",
+			Status:   StatusUp,
+			Metadata: entry.Service.Meta,
+		})
+
+	}
+
+	return instances, nil
+
+}
+
+// * =========== *
+
+// register sends the instance with its TTL check to the Consul agent. The check starts passing, or warning when the
+// status provider does not report UP, so the instance is found without waiting for the first heartbeat.
+func (cr *ConsulRegistry) register(instance Instance) error {
+
+	checkStatus := "passing"
+	if cr.status() != StatusUp {
+		checkStatus = "warning"
+	}
+
+	service := consulService{
+		ID:      instance.ID,
+		Name:    serviceName(instance.AppName),
+		Address: cr.host,
+		Port:    instance.Port,
+		Meta:    cr.metadata,
+		Check: &consulCheck{
+			CheckID: checkID(instance.ID),
+			TTL:     cr.ttl.String(),
+			Status:  checkStatus,
+		},
+	}
+
+	if cr.deregisterAfter > 0 {
+		service.Check.DeregisterCriticalServiceAfter = cr.deregisterAfter.String()
+	}
+
+	return cr.send(http.MethodPut, "/v1/agent/service/register", service, nil)
+
+}
+
+// * =========== *
+
+// status returns the status of the status provider, UP when there is none
+func (cr *ConsulRegistry) status() string {
+
+	cr.mu.Lock()
+	provider := cr.statusProvider
+	cr.mu.Unlock()
+
+	if provider == nil {
+		return StatusUp
+	}
+
+	return provider(context.Background())
+
+}
+
+// * =========== *
+
+// keepAlive passes the TTL check every half TTL until the loop is stopped, and registers the instance again when the
+// agent no longer knows it
+func (cr *ConsulRegistry) keepAlive(instance Instance, loop *heartbeatLoop) {
+
+	defer close(loop.done)
+
+	interval := cr.ttl / 2
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	for {
+
+		select {
+		case <-loop.stop:
+			return
+		case <-time.After(interval):
+		}
+
+		err := cr.Heartbeat(instance)
+		if errors.Is(err, ErrNotRegistered) {
+
+			// The agent forgets the instance once it is deregistered, which is no reason to register it again
+			select {
+			case <-loop.stop:
+				return
+			default:
+			}
+
+			log.Printf("the Consul agent no longer knows instance %s, registering it again", instance.ID)
+			err = cr.register(instance)
+
+		}
+
+		if err != nil {
+			log.Printf("couldn't pass the TTL check on the Consul agent: %s", err.Error())
+		}
+
+	}
+
+}
+
+// * =========== *
+
+// send sends the request to the Consul agent and decodes the answer into out when it is not nil
+func (cr *ConsulRegistry) send(method string, path string, in interface{}, out interface{}) error {
+
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, cr.url+path, body)
+	if err != nil {
+		return err
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if cr.token != "" {
+		req.Header.Set("X-Consul-Token", cr.token)
+	}
+
+	resp, err := cr.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &consulStatusError{method, req.URL.Path, resp.StatusCode}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+
+}
+
+// * =========== *
+
+func (se *consulStatusError) Error() string {
+	return fmt.Sprintf("the Consul agent answered %s %s with status %d", se.method, se.path, se.statusCode)
+}
+
+// ? ==================== Functions ==================== ?
+
+// serviceName returns the name of the service in Consul, which are lower case by convention
+func serviceName(appName string) string {
+	return strings.ToLower(appName)
+}
+
+// * =========== *
+
+// checkID returns the id of the TTL check of the instance, the same one the Consul agent gives to the check of a
+// service
+func checkID(instanceId string) string {
+	return "service:" + instanceId
+}
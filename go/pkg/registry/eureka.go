@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"MicroserviceTemplate/pkg/eureka"
+)
+
+// ? ==================== Structs ==================== ?
+
+// EurekaRegistry registers the instance with the Eureka client, which keeps it alive with its own heartbeats, and finds
+// the instances in the registry cache of the discovery client
+type EurekaRegistry struct {
+	client    eureka.IClient
+	discovery eureka.IDiscoveryClient
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewEurekaRegistry returns a new registry backed by the Eureka servers
+func NewEurekaRegistry(client eureka.IClient, discovery eureka.IDiscoveryClient) *EurekaRegistry {
+	return &EurekaRegistry{client, discovery}
+}
+
+// ? ==================== Methods ==================== ?
+
+// Register registers the instance and sends heartbeats until it is deregistered
+func (er *EurekaRegistry) Register(instance Instance) error {
+
+	er.client.StartClient(instance.AppName, instance.ID, instance.Port)
+
+	return nil
+
+}
+
+// * =========== *
+
+// Deregister stops the heartbeats, marks the instance as DOWN and deregisters it
+func (er *EurekaRegistry) Deregister(instance Instance) error {
+
+	er.client.Stop(instance.AppName, instance.ID, instance.Port)
+
+	return nil
+
+}
+
+// * =========== *
+
+// Heartbeat renews the lease of the instance, registering it again when the Eureka server no longer knows it
+func (er *EurekaRegistry) Heartbeat(instance Instance) error {
+	return er.client.Renew(instance.AppName, instance.ID, instance.Port)
+}
+
+// * =========== *
+
+// GetInstances returns the instances of the application from the registry cache
+func (er *EurekaRegistry) GetInstances(appName string) ([]Instance, error) {
+
+	details := er.discovery.GetInstances(appName)
+
+	instances := make([]Instance, 0, len(details))
+	for _, instance := range details {
+		instances = append(instances, fromEureka(instance))
+	}
+
+	return instances, nil
+
+}
+
+// * =========== *
+
+// InstanceStatus returns the last status sent to the Eureka server and the status set by an operator
+func (er *EurekaRegistry) InstanceStatus() InstanceStatus {
+
+	status := er.client.InstanceStatus()
+
+	return InstanceStatus{Status: status.Status, OverriddenStatus: status.OverriddenStatus}
+
+}
+
+// * =========== *
+
+// OverrideStatus sets the status of the instance with the override API of the Eureka server
+func (er *EurekaRegistry) OverrideStatus(status string) error {
+	return er.client.OverrideStatus(status)
+}
+
+// ? ==================== Functions ==================== ?
+
+// fromEureka returns the instance registered in Eureka, reached on its secure port when it is enabled
+func fromEureka(details eureka.InstanceDetails) Instance {
+
+	host := details.HostName
+	if host == "" {
+		host = details.IpAddr
+	}
+
+	secure := details.SecurePort.Enabled == "true"
+
+	port := details.Port.Port
+	if secure {
+		port = details.SecurePort.Port
+	}
+
+	return Instance{
+		ID:       details.InstanceId,
+		AppName:  details.App,
+		Host:     host,
+		Port:     port,
+		Secure:   secure,
+		Status:   details.Status,
+		Metadata: details.Metadata,
+	}
+
+}
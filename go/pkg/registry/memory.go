@@ -0,0 +1,134 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ? ==================== Structs ==================== ?
+
+// InMemoryRegistry keeps the instances in memory, for the tests and for running the application without any registry
+type InMemoryRegistry struct {
+	mu         sync.RWMutex
+	instances  map[string]map[string]Instance
+	heartbeats map[string]time.Time
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewInMemoryRegistry returns a new empty registry
+func NewInMemoryRegistry() *InMemoryRegistry {
+	return &InMemoryRegistry{
+		instances:  map[string]map[string]Instance{},
+		heartbeats: map[string]time.Time{},
+	}
+}
+
+// ? ==================== Methods ==================== ?
+
+// Register adds the instance, UP unless it has another status
+func (mr *InMemoryRegistry) Register(instance Instance) error {
+
+	if instance.Status == "" {
+		instance.Status = StatusUp
+	}
+
+	name := strings.ToUpper(instance.AppName)
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if mr.instances[name] == nil {
+		mr.instances[name] = map[string]Instance{}
+	}
+
+	mr.instances[name][instance.ID] = instance
+	mr.heartbeats[instance.ID] = time.Now()
+
+	return nil
+
+}
+
+// * =========== *
+
+// Deregister removes the instance
+func (mr *InMemoryRegistry) Deregister(instance Instance) error {
+
+	name := strings.ToUpper(instance.AppName)
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if _, ok := mr.instances[name][instance.ID]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotRegistered, instance.ID)
+	}
+
+	delete(mr.instances[name], instance.ID)
+	delete(mr.heartbeats, instance.ID)
+
+	if len(mr.instances[name]) == 0 {
+		delete(mr.instances, name)
+	}
+
+	return nil
+
+}
+
+// * =========== *
+
+// Heartbeat records the time of the heartbeat of the instance
+func (mr *InMemoryRegistry) Heartbeat(instance Instance) error {
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if _, ok := mr.instances[strings.ToUpper(instance.AppName)][instance.ID]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotRegistered, instance.ID)
+	}
+
+	mr.heartbeats[instance.ID] = time.Now()
+
+	return nil
+
+}
+
+// * =========== *
+
+// LastHeartbeat returns the time of the last heartbeat or registration of the instance
+func (mr *InMemoryRegistry) LastHeartbeat(instanceId string) (time.Time, bool) {
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	last, ok := mr.heartbeats[instanceId]
+
+	return last, ok
+
+}
+
+// * =========== *
+
+// GetInstances returns the UP instances of the application sorted by id
+func (mr *InMemoryRegistry) GetInstances(appName string) ([]Instance, error) {
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	var instances []Instance
+
+	for _, instance := range mr.instances[strings.ToUpper(appName)] {
+		if instance.Status == StatusUp {
+			instances = append(instances, instance)
+		}
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+
+	return instances, nil
+
+}
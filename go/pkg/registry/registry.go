@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/eureka"
+	"MicroserviceTemplate/pkg/network"
+	"errors"
+	"log"
+	"os"
+)
+
+// ? ==================== Interfaces ==================== ?
+
+// IServiceRegistry registers the instance in a service registry and finds the instances of the other services in it
+type IServiceRegistry interface {
+	Register(instance Instance) error
+	Deregister(instance Instance) error
+	Heartbeat(instance Instance) error
+	GetInstances(appName string) ([]Instance, error)
+}
+
+// * =========== *
+
+// IStatusOverrider is implemented by the registries that let an operator override the status of the instance, like the
+// service-registry endpoint of Spring Cloud
+type IStatusOverrider interface {
+	InstanceStatus() InstanceStatus
+	OverrideStatus(status string) error
+}
+
+// ? ==================== Structs ==================== ?
+
+// Instance is an instance of a service in the registry. The instance registered by the application is built by
+// NewLocalInstance, the Eureka and Consul registries resolve its address from their own configuration.
+type Instance struct {
+	ID       string            `json:"id"`
+	AppName  string            `json:"appName"`
+	Host     string            `json:"host"`
+	Port     int               `json:"port"`
+	Secure   bool              `json:"secure"`
+	Status   string            `json:"status"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// * =========== *
+
+// InstanceStatus is the status of the instance in the registry and the status set by an operator
+type InstanceStatus struct {
+	Status           string `json:"status"`
+	OverriddenStatus string `json:"overriddenStatus"`
+}
+
+// ? ==================== Constants ==================== ?
+
+const (
+	TypeEureka = "eureka"
+	TypeConsul = "consul"
+	TypeMemory = "memory"
+
+	// StatusUp is the status of the instances that can serve requests
+	StatusUp = "UP"
+)
+
+// ? ==================== Variables ==================== ?
+
+// ErrNotRegistered is returned when the heartbeat or the deregistration is sent for an instance the registry does not
+// know
+var ErrNotRegistered = errors.New("the instance is not registered")
+
+// ErrInvalidStatus is returned when an operator overrides the status with one the registry does not accept
+var ErrInvalidStatus = eureka.ErrInvalidStatus
+
+// ? ==================== Constructors ==================== ?
+
+// NewServiceRegistry returns the registry of registry.type
+func NewServiceRegistry(registry config.RegistryConfig, consul config.ConsulConfig, server config.ServerConfig, eurekaClient eureka.IClient, discovery eureka.IDiscoveryClient) IServiceRegistry {
+
+	log.Printf("using the %s service registry", registry.Type)
+
+	switch registry.Type {
+	case TypeConsul:
+		return NewConsulRegistry(consul, server)
+	case TypeMemory:
+		return NewInMemoryRegistry()
+	default:
+		return NewEurekaRegistry(eurekaClient, discovery)
+	}
+
+}
+
+// * =========== *
+
+// NewLocalInstance returns the instance of the application listening on the port, on the host resolved like the
+// registries do: server.hostname, then the hostname of the machine, then the outbound IP address
+func NewLocalInstance(id string, appName string, port int, server config.ServerConfig) Instance {
+
+	host := server.Hostname
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	if host == "" {
+		host = network.OutboundIP()
+	}
+
+	return Instance{
+		ID:      id,
+		AppName: appName,
+		Host:    host,
+		Port:    port,
+		Secure:  server.SSL.Enabled,
+	}
+
+}
@@ -1,12 +1,15 @@
 package product
 
 import (
+	"MicroserviceTemplate/config"
 	"context"
+	"errors"
 	"fmt"
-	"github.com/spf13/viper"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"log"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,54 +17,63 @@ import (
 
 type IProductStore interface {
 	InitDatabase(collection string) (*mongo.Collection, error)
+	Disconnect(ctx context.Context) error
+	Ping(ctx context.Context) error
+	Timeouts() config.DatabaseTimeoutsConfig
+	SetTimeouts(timeouts config.DatabaseTimeoutsConfig)
 }
 
 // ? =================== Structs =================== ?
 
-type Store struct{}
+type Store struct {
+	config   config.DatabaseConfig
+	client   *mongo.Client
+	timeouts atomic.Value
+}
 
 // ? =================== Constructors =================== ?
 
-func NewStore() IProductStore {
-	return &Store{}
+func NewStore(config config.DatabaseConfig) IProductStore {
+
+	store := &Store{config: config}
+	store.timeouts.Store(config.Timeouts)
+
+	return store
+
 }
 
 // ? =================== Functions =================== ?
 
+// InitDatabase connects to MongoDB the first time it is called and returns the collection
 func (s *Store) InitDatabase(collection string) (*mongo.Collection, error) {
 
-	var dsn string
-
-	usernameDb := viper.GetString("database.username")
-	passwordDb := viper.GetString("database.password")
-
-	hostDb := viper.GetString("database.host")
-	if hostDb == "" {
-		hostDb = "localhost"
+	if s.client != nil {
+		return s.client.Database(s.config.Name).Collection(collection), nil
 	}
 
-	portDb := viper.GetString("database.port")
-	if portDb == "" {
-		portDb = "27017"
-	}
+	var dsn string
 
-	nameDb := viper.GetString("database.name")
-	if nameDb == "" {
-		nameDb = "microservice_go_template"
+	if s.config.Username == "" || s.config.Password == "" {
+		dsn = fmt.Sprintf("mongodb://%s:%d", s.config.Host, s.config.Port)
+	} else {
+		dsn = fmt.Sprintf("mongodb://%s:%s@%s:%d", s.config.Username, s.config.Password, s.config.Host, s.config.Port)
 	}
 
-	if usernameDb == "" || passwordDb == "" {
-		dsn = fmt.Sprintf("mongodb://%s:%s", hostDb, portDb)
-	} else {
-		dsn = fmt.Sprintf("mongodb://%s:%s@%s:%s", usernameDb, passwordDb, hostDb, portDb)
+	clientOptions := options.Client().ApplyURI(dsn)
+
+	// The pool size is only read when connecting, the driver cannot resize the pool of a connected client
+	if s.config.Pool.MaxSize > 0 {
+		clientOptions.SetMaxPoolSize(s.config.Pool.MaxSize)
 	}
 
-	db, err := mongo.NewClient(options.Client().ApplyURI(dsn))
+	db, err := mongo.NewClient(clientOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	err = db.Connect(ctx)
 
 	if err != nil {
@@ -70,6 +82,50 @@ func (s *Store) InitDatabase(collection string) (*mongo.Collection, error) {
 
 	log.Println("Connected to MongoDB!")
 
-	return db.Database(nameDb).Collection(collection), nil
+	s.client = db
+
+	return db.Database(s.config.Name).Collection(collection), nil
+
+}
+
+// * =========== *
+
+// Ping checks that the primary of the MongoDB deployment answers
+func (s *Store) Ping(ctx context.Context) error {
+
+	if s.client == nil {
+		return errors.New("the database has not been initialized")
+	}
+
+	return s.client.Ping(ctx, readpref.Primary())
+
+}
+
+// * =========== *
+
+// Disconnect closes the connections to MongoDB, waiting for the operations in progress until the context is done
+func (s *Store) Disconnect(ctx context.Context) error {
+
+	if s.client == nil {
+		return nil
+	}
+
+	log.Println("disconnecting from MongoDB")
+
+	return s.client.Disconnect(ctx)
+
+}
+
+// * =========== *
+
+// Timeouts returns the timeouts of the database operations
+func (s *Store) Timeouts() config.DatabaseTimeoutsConfig {
+	return s.timeouts.Load().(config.DatabaseTimeoutsConfig)
+}
+
+// * =========== *
 
+// SetTimeouts replaces the timeouts of the database operations, they apply from the next operation
+func (s *Store) SetTimeouts(timeouts config.DatabaseTimeoutsConfig) {
+	s.timeouts.Store(timeouts)
 }
@@ -0,0 +1,47 @@
+package web
+
+// ? ==================== Structs ==================== ?
+
+// Error is an error that knows the HTTP status and the code it is reported with, for the handlers whose errors do not
+// come from a package with errors of its own
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Err     error
+}
+
+// ? ==================== Constructors ==================== ?
+
+// NewError returns a new error reported with the status and the code
+func NewError(status int, code string, message string, err error) *Error {
+	return &Error{Status: status, Code: code, Message: message, Err: err}
+}
+
+// ? ==================== Methods ==================== ?
+
+// Error returns the message of the error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// * =========== *
+
+// Unwrap returns the error that caused it
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// * =========== *
+
+// StatusCode returns the HTTP status the error is reported with
+func (e *Error) StatusCode() int {
+	return e.Status
+}
+
+// * =========== *
+
+// ErrorCode returns the machine-readable code of the error
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
@@ -12,6 +12,28 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
+// * =========== *
+
+// ProblemDetails is the body of an error response as defined by RFC 7807
+type ProblemDetails struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// * =========== *
+
+// FieldError describes a rule broken by a field of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
 // ? ==================== Functions ====================
 
 // SuccessResponseBody returns a response with a status code passed by parameter
@@ -29,3 +51,11 @@ func ErrorResponseBody(ctx *gin.Context, status int, code string, message string
 		Message: message,
 	})
 }
+
+// * =========== *
+
+// ProblemResponseBody returns an application/problem+json response with the status of the problem
+func ProblemResponseBody(ctx *gin.Context, problem ProblemDetails) {
+	ctx.Header("Content-Type", "application/problem+json")
+	ctx.JSON(problem.Status, problem)
+}
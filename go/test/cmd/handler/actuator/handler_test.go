@@ -0,0 +1,122 @@
+package actuator
+
+import (
+	handlerActuator "MicroserviceTemplate/cmd/handler/actuator"
+	routerActuator "MicroserviceTemplate/cmd/router/actuator"
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/middleware"
+	"MicroserviceTemplate/pkg/registry"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Actuator Handler Suite")
+}
+
+// overridableRegistry is a registry that lets an operator override the status of the instance, like Eureka does
+type overridableRegistry struct {
+	*registry.InMemoryRegistry
+	status registry.InstanceStatus
+}
+
+func (or *overridableRegistry) InstanceStatus() registry.InstanceStatus {
+	return or.status
+}
+
+func (or *overridableRegistry) OverrideStatus(status string) error {
+
+	if status != "UP" && status != "OUT_OF_SERVICE" {
+		return registry.ErrInvalidStatus
+	}
+
+	or.status.OverriddenStatus = status
+
+	return nil
+
+}
+
+// serve runs the request through the actuator routes with the realm roles of a token already verified
+func serve(serviceRegistry registry.IServiceRegistry, registryType string, method string, path string, body string, roles ...string) *httptest.ResponseRecorder {
+
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(middleware.ProblemDetails())
+	r.Use(func(c *gin.Context) {
+		var claims middleware.Claims
+		realmAccess, _ := json.Marshal(map[string]interface{}{"realm_access": map[string]interface{}{"roles": roles}})
+		_ = json.Unmarshal(realmAccess, &claims)
+		c.Set(middleware.ClaimsKey, &claims)
+	})
+
+	handler := handlerActuator.NewHandler(nil, serviceRegistry, config.RegistryConfig{Type: registryType})
+	r = routerActuator.NewActuatorRouter(handler, config.SecurityConfig{AdminRole: "ADMIN"}).GetRoutes(r)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(recorder, request)
+
+	return recorder
+
+}
+
+var _ = Describe("Actuator", func() {
+
+	var eurekaRegistry *overridableRegistry
+
+	BeforeEach(func() {
+		eurekaRegistry = &overridableRegistry{registry.NewInMemoryRegistry(), registry.InstanceStatus{Status: "UP", OverriddenStatus: "UNKNOWN"}}
+	})
+
+	Context("Service registry", func() {
+
+		It("Lets the admin override the status of the instance", func() {
+
+			recorder := serve(eurekaRegistry, registry.TypeEureka, http.MethodPost, "/actuator/service-registry", `{"status":"OUT_OF_SERVICE"}`, "USER", "ADMIN")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"status":"UP","overriddenStatus":"OUT_OF_SERVICE"}`))
+
+		})
+
+		It("Forbids the override to the users without the admin role", func() {
+
+			recorder := serve(eurekaRegistry, registry.TypeEureka, http.MethodPost, "/actuator/service-registry", `{"status":"OUT_OF_SERVICE"}`, "USER")
+
+			Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			Expect(eurekaRegistry.status.OverriddenStatus).To(Equal("UNKNOWN"))
+
+		})
+
+		It("Answers the invalid statuses with a problem", func() {
+
+			recorder := serve(eurekaRegistry, registry.TypeEureka, http.MethodPost, "/actuator/service-registry", `{"status":"DOWN"}`, "ADMIN")
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("application/problem+json"))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"code":"invalid_status"`))
+
+		})
+
+		It("Answers 501 when the registry does not support the status of the instance", func() {
+
+			recorder := serve(registry.NewInMemoryRegistry(), registry.TypeMemory, http.MethodGet, "/actuator/service-registry", "", "ADMIN")
+
+			Expect(recorder.Code).To(Equal(http.StatusNotImplemented))
+			Expect(recorder.Body.String()).To(ContainSubstring(`"code":"registry_unsupported"`))
+			Expect(recorder.Body.String()).To(ContainSubstring("the memory service registry does not support"))
+
+		})
+
+	})
+
+})
@@ -0,0 +1,530 @@
+package product
+
+import (
+	handlerProduct "MicroserviceTemplate/cmd/handler/product"
+	routerProduct "MicroserviceTemplate/cmd/router/product"
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/internal/domain"
+	"MicroserviceTemplate/internal/product"
+	"MicroserviceTemplate/pkg/middleware"
+	"MicroserviceTemplate/pkg/pagination"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Product Handler Suite")
+}
+
+// fakeService answers with the functions that are set and fails the calls to the others
+type fakeService struct {
+	product.IService
+	getAll      func(ctx context.Context, query *domain.ProductQuery) (*domain.ProductPage, error)
+	getByID     func(ctx context.Context, id string) (*domain.Product, error)
+	update      func(ctx context.Context, product *domain.Product, expectedVersion int64) (*domain.Product, error)
+	patchUpdate func(ctx context.Context, id string, patch []byte, patchType string, expectedVersion int64) (*domain.Product, error)
+	delete      func(ctx context.Context, id string, expectedVersion int64) error
+}
+
+func (fs *fakeService) GetAll(ctx context.Context, query *domain.ProductQuery) (*domain.ProductPage, error) {
+	return fs.getAll(ctx, query)
+}
+
+func (fs *fakeService) GetByID(ctx context.Context, id string) (*domain.Product, error) {
+	return fs.getByID(ctx, id)
+}
+
+func (fs *fakeService) Update(ctx context.Context, product *domain.Product, expectedVersion int64) (*domain.Product, error) {
+	return fs.update(ctx, product, expectedVersion)
+}
+
+func (fs *fakeService) PatchUpdate(ctx context.Context, id string, patch []byte, patchType string, expectedVersion int64) (*domain.Product, error) {
+	return fs.patchUpdate(ctx, id, patch, patchType, expectedVersion)
+}
+
+func (fs *fakeService) Delete(ctx context.Context, id string, expectedVersion int64) error {
+	return fs.delete(ctx, id, expectedVersion)
+}
+
+// memoryRepository keeps a single product, so the real service can be run behind the handler
+type memoryRepository struct {
+	product.IRepository
+	stored *domain.Product
+}
+
+func (mr *memoryRepository) GetByID(_ context.Context, id string) (*domain.Product, error) {
+	if mr.stored == nil || mr.stored.ID != id {
+		return nil, product.ErrNotFound
+	}
+	stored := *mr.stored
+	return &stored, nil
+}
+
+func (mr *memoryRepository) Save(_ context.Context, productToSave *domain.Product) (domain.Product, error) {
+	productToSave.ID = "1"
+	productToSave.Version = 1
+	stored := *productToSave
+	mr.stored = &stored
+	return stored, nil
+}
+
+func (mr *memoryRepository) Update(ctx context.Context, productToUpdate *domain.Product, expectedVersion int64) (*domain.Product, error) {
+
+	if _, err := mr.GetByID(ctx, productToUpdate.ID); err != nil {
+		return nil, err
+	}
+
+	if expectedVersion != product.AnyVersion && expectedVersion != mr.stored.Version {
+		return nil, product.ErrVersionMismatch
+	}
+
+	updated := *productToUpdate
+	updated.Version = mr.stored.Version + 1
+	mr.stored = &updated
+
+	return &updated, nil
+
+}
+
+// newService returns the real product service over a repository with the product
+func newService(stored *domain.Product) (product.IService, *memoryRepository) {
+	repository := &memoryRepository{stored: stored}
+	return product.NewService(repository, pagination.NewTokenCodec(config.PaginationConfig{TokenSecret: "secret"})), repository
+}
+
+// serve runs the request through the product routes and the problem details middleware
+func serve(service product.IService, request *http.Request) *httptest.ResponseRecorder {
+
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(middleware.ProblemDetails())
+	r = routerProduct.NewProductRouter(handlerProduct.NewHandler(service)).GetRoutes(r)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, request)
+
+	return recorder
+
+}
+
+// problem decodes the RFC 7807 body of the response
+func problem(recorder *httptest.ResponseRecorder) map[string]interface{} {
+
+	Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("application/problem+json"))
+
+	var body map[string]interface{}
+	Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+
+	return body
+
+}
+
+// request returns a new request with the body
+func request(method string, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body)
+}
+
+var _ = Describe("Product handler", func() {
+
+	Context("Listing", func() {
+
+		// listing returns a service that records the query and answers with an empty page of the total
+		listing := func(received **domain.ProductQuery, total int64) *fakeService {
+			return &fakeService{getAll: func(_ context.Context, query *domain.ProductQuery) (*domain.ProductPage, error) {
+				*received = query
+				totalPages := int((total + int64(query.Size) - 1) / int64(query.Size))
+				return &domain.ProductPage{Items: domain.Products{}, Page: query.Page, Size: query.Size, Total: total, TotalPages: totalPages}, nil
+			}}
+		}
+
+		It("Parses the pagination, sort and filters of the query", func() {
+
+			var query *domain.ProductQuery
+
+			recorder := serve(listing(&query, 0), request(http.MethodGet, "/products/?page=2&size=10&sort=price,DESC&name=cof&minPrice=1.5&maxPrice=10&minQuantity=1&maxQuantity=5", nil))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(query.Page).To(Equal(2))
+			Expect(query.Size).To(Equal(10))
+			Expect(query.SortField).To(Equal("price"))
+			Expect(query.SortOrder).To(Equal(domain.SortDescending))
+			Expect(query.NamePrefix).To(Equal("cof"))
+			Expect(*query.MinPrice).To(Equal(1.5))
+			Expect(*query.MaxPrice).To(Equal(10.0))
+			Expect(*query.MinQuantity).To(Equal(1))
+			Expect(*query.MaxQuantity).To(Equal(5))
+
+		})
+
+		It("Uses the first page sorted by ID by default", func() {
+
+			var query *domain.ProductQuery
+
+			recorder := serve(listing(&query, 0), request(http.MethodGet, "/products/", nil))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(query).To(Equal(domain.NewProductQuery()))
+
+		})
+
+		It("Links the neighbouring pages", func() {
+
+			var query *domain.ProductQuery
+
+			recorder := serve(listing(&query, 50), request(http.MethodGet, "/products/?page=1&size=20", nil))
+
+			var page domain.ProductPage
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &page)).To(Succeed())
+
+			Expect(page.TotalPages).To(Equal(3))
+			Expect(page.Links.First).To(Equal("/products/?page=0&size=20"))
+			Expect(page.Links.Prev).To(Equal("/products/?page=0&size=20"))
+			Expect(page.Links.Next).To(Equal("/products/?page=2&size=20"))
+			Expect(page.Links.Last).To(Equal("/products/?page=2&size=20"))
+
+		})
+
+		It("Keeps the links within the numbered pages and follows the continuation token past them", func() {
+
+			var query *domain.ProductQuery
+
+			service := listing(&query, int64(domain.MaxPage+1)*10+5)
+			getAll := service.getAll
+			service.getAll = func(ctx context.Context, query *domain.ProductQuery) (*domain.ProductPage, error) {
+				page, err := getAll(ctx, query)
+				page.NextToken = "token"
+				return page, err
+			}
+
+			var page domain.ProductPage
+
+			recorder := serve(service, request(http.MethodGet, "/products/?size=10", nil))
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &page)).To(Succeed())
+
+			Expect(page.TotalPages).To(Equal(domain.MaxPage + 2))
+			Expect(page.Links.Last).To(Equal(fmt.Sprintf("/products/?page=%d&size=10", domain.MaxPage)))
+
+			recorder = serve(service, request(http.MethodGet, fmt.Sprintf("/products/?page=%d&size=10", domain.MaxPage), nil))
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &page)).To(Succeed())
+
+			Expect(page.Links.Last).To(Equal(fmt.Sprintf("/products/?page=%d&size=10", domain.MaxPage)))
+			Expect(page.Links.Next).To(Equal("/products/?after=token&size=10"))
+			Expect(serve(service, request(http.MethodGet, page.Links.Last, nil)).Code).To(Equal(http.StatusOK))
+
+		})
+
+		DescribeTable("Rejects an invalid query with 400",
+			func(rawQuery string, detail string) {
+
+				var query *domain.ProductQuery
+
+				recorder := serve(listing(&query, 0), request(http.MethodGet, "/products/?"+rawQuery, nil))
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(problem(recorder)["detail"]).To(ContainSubstring(detail))
+				Expect(query).To(BeNil())
+
+			},
+			Entry("negative page", "page=-1", "page must be an integer between 0 and 1000"),
+			Entry("page beyond the cap", "page=1001", "page must be an integer between 0 and 1000"),
+			Entry("page that overflows", "page=9223372036854775807", "page must be an integer between 0 and 1000"),
+			Entry("zero size", "size=0", "size must be an integer between 1 and 100"),
+			Entry("size beyond the cap", "size=101", "size must be an integer between 1 and 100"),
+			Entry("unknown sort field", "sort=color", `products cannot be sorted by "color"`),
+			Entry("unknown sort direction", "sort=price,up", "sort direction must be asc or desc"),
+			Entry("price that is not a number", "minPrice=cheap", "minPrice must be a number"),
+			Entry("inverted price range", "minPrice=10&maxPrice=1", "minPrice cannot be greater than maxPrice"),
+			Entry("inverted quantity range", "minQuantity=5&maxQuantity=1", "minQuantity cannot be greater than maxQuantity"),
+		)
+
+	})
+
+	Context("Concurrency control", func() {
+
+		stored := &domain.Product{ID: "1", Name: "Coffee", Quantity: 3, Price: 2.5, Version: 7}
+
+		// deleting returns a service that records the version the delete expects
+		deleting := func(expected *int64) *fakeService {
+			return &fakeService{
+				getByID: func(_ context.Context, _ string) (*domain.Product, error) {
+					return stored, nil
+				},
+				delete: func(_ context.Context, _ string, expectedVersion int64) error {
+					*expected = expectedVersion
+					if expectedVersion != product.AnyVersion && expectedVersion != stored.Version {
+						return product.ErrVersionMismatch
+					}
+					return nil
+				},
+			}
+		}
+
+		// deleteWithIfMatch sends a delete with the If-Match header
+		deleteWithIfMatch := func(service product.IService, ifMatch string) *httptest.ResponseRecorder {
+			req := request(http.MethodDelete, "/products/1", nil)
+			if ifMatch != "" {
+				req.Header.Set("If-Match", ifMatch)
+			}
+			return serve(service, req)
+		}
+
+		It("Returns the version of the product as its ETag", func() {
+
+			recorder := serve(deleting(new(int64)), request(http.MethodGet, "/products/1", nil))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("ETag")).To(Equal(`"7"`))
+
+		})
+
+		It("Deletes without a body", func() {
+
+			var expected int64
+			recorder := deleteWithIfMatch(deleting(&expected), `"7"`)
+
+			Expect(recorder.Code).To(Equal(http.StatusNoContent))
+			Expect(recorder.Body.Len()).To(BeZero())
+			Expect(expected).To(Equal(int64(7)))
+
+		})
+
+		It("Accepts any version without If-Match or with *", func() {
+
+			var expected int64
+
+			Expect(deleteWithIfMatch(deleting(&expected), "").Code).To(Equal(http.StatusNoContent))
+			Expect(expected).To(Equal(product.AnyVersion))
+
+			Expect(deleteWithIfMatch(deleting(&expected), "*").Code).To(Equal(http.StatusNoContent))
+			Expect(expected).To(Equal(product.AnyVersion))
+
+		})
+
+		It("Expects the current version when If-Match lists several", func() {
+
+			var expected int64
+			recorder := deleteWithIfMatch(deleting(&expected), `"5", W/"6", "7"`)
+
+			Expect(recorder.Code).To(Equal(http.StatusNoContent))
+			Expect(expected).To(Equal(int64(7)))
+
+		})
+
+		DescribeTable("Answers 412 when If-Match cannot match the stored version",
+			func(ifMatch string) {
+
+				expected := int64(-2)
+				recorder := deleteWithIfMatch(deleting(&expected), ifMatch)
+
+				Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+				Expect(problem(recorder)["code"]).To(Equal("precondition_failed"))
+
+			},
+			Entry("another version", `"6"`),
+			Entry("a weak tag of the version", `W/"7"`),
+			Entry("only other versions", `"5", "6"`),
+			Entry("an unquoted tag", `7`),
+			Entry("a tag that is not a version", `"abc"`),
+		)
+
+	})
+
+	Context("Patch", func() {
+
+		var service product.IService
+		var repository *memoryRepository
+
+		BeforeEach(func() {
+			service, repository = newService(&domain.Product{ID: "1", Name: "Coffee", Quantity: 3, Price: 2.5, Version: 1})
+		})
+
+		// patch sends the document with the content type
+		patch := func(contentType string, document string) *httptest.ResponseRecorder {
+			req := request(http.MethodPatch, "/products/1", strings.NewReader(document))
+			req.Header.Set("Content-Type", contentType)
+			return serve(service, req)
+		}
+
+		It("Applies a JSON Merge Patch that zeroes a field", func() {
+
+			recorder := patch(product.MergePatch, `{"quantity": 0}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("ETag")).To(Equal(`"2"`))
+			Expect(repository.stored.Quantity).To(BeZero())
+			Expect(repository.stored.Name).To(Equal("Coffee"))
+
+		})
+
+		It("Takes application/json as a JSON Merge Patch", func() {
+
+			recorder := patch("application/json", `{"price": 3.75}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(repository.stored.Price).To(Equal(3.75))
+
+		})
+
+		It("Applies a JSON Patch with test operations", func() {
+
+			recorder := patch(product.JSONPatch, `[{"op": "test", "path": "/quantity", "value": 3}, {"op": "replace", "path": "/quantity", "value": 0}]`)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(repository.stored.Quantity).To(BeZero())
+
+		})
+
+		It("Answers 409 when a test operation does not hold", func() {
+
+			recorder := patch(product.JSONPatch, `[{"op": "test", "path": "/quantity", "value": 5}, {"op": "replace", "path": "/quantity", "value": 0}]`)
+
+			Expect(recorder.Code).To(Equal(http.StatusConflict))
+			Expect(problem(recorder)["code"]).To(Equal("conflict"))
+			Expect(repository.stored.Quantity).To(Equal(3))
+
+		})
+
+		It("Answers 400 to a malformed patch", func() {
+
+			recorder := patch(product.JSONPatch, `{"op": "replace"}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(problem(recorder)["code"]).To(Equal("validation"))
+
+		})
+
+		It("Answers 415 to another media type", func() {
+
+			recorder := patch("text/plain", `quantity=0`)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnsupportedMediaType))
+			Expect(problem(recorder)["code"]).To(Equal("unsupported_media_type"))
+
+		})
+
+		It("Keeps the identity and version of the product", func() {
+
+			recorder := patch(product.MergePatch, `{"_id": "2", "version": 40}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(repository.stored.ID).To(Equal("1"))
+			Expect(repository.stored.Version).To(Equal(int64(2)))
+
+		})
+
+	})
+
+	Context("Validation", func() {
+
+		// fields returns the fields listed in the errors of the problem
+		fields := func(body map[string]interface{}) []string {
+
+			var names []string
+			for _, fieldError := range body["errors"].([]interface{}) {
+				names = append(names, fieldError.(map[string]interface{})["field"].(string))
+			}
+
+			return names
+
+		}
+
+		It("Lists every invalid field of a new product in the problem", func() {
+
+			service, repository := newService(nil)
+
+			req := request(http.MethodPost, "/products/", strings.NewReader(`{"name": "", "quantity": -1, "price": 1.234}`))
+			req.Header.Set("Content-Type", "application/json")
+
+			recorder := serve(service, req)
+			body := problem(recorder)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(body["type"]).To(Equal("about:blank"))
+			Expect(body["title"]).To(Equal(http.StatusText(http.StatusBadRequest)))
+			Expect(body["status"]).To(BeEquivalentTo(http.StatusBadRequest))
+			Expect(body["instance"]).To(Equal("/products/"))
+			Expect(body["code"]).To(Equal("validation"))
+			Expect(fields(body)).To(ConsistOf("name", "quantity", "price"))
+			Expect(repository.stored).To(BeNil())
+
+		})
+
+		It("Validates a replaced product with the same rules", func() {
+
+			service, repository := newService(&domain.Product{ID: "1", Name: "Coffee", Quantity: 3, Price: 2.5, Version: 1})
+
+			req := request(http.MethodPut, "/products/1", strings.NewReader(`{"name": "Coffee", "quantity": 3, "price": 0}`))
+			req.Header.Set("Content-Type", "application/json")
+
+			recorder := serve(service, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(fields(problem(recorder))).To(ConsistOf("price"))
+			Expect(repository.stored.Price).To(Equal(2.5))
+
+		})
+
+		It("Validates a patched product with the same rules", func() {
+
+			service, repository := newService(&domain.Product{ID: "1", Name: "Coffee", Quantity: 3, Price: 2.5, Version: 1})
+
+			req := request(http.MethodPatch, "/products/1", strings.NewReader(`{"name": "C"}`))
+			req.Header.Set("Content-Type", product.MergePatch)
+
+			recorder := serve(service, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(fields(problem(recorder))).To(ConsistOf("name"))
+			Expect(repository.stored.Name).To(Equal("Coffee"))
+
+		})
+
+		It("Answers 404 with a problem when the product does not exist", func() {
+
+			service, _ := newService(nil)
+
+			recorder := serve(service, request(http.MethodGet, "/products/missing", nil))
+			body := problem(recorder)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(body["code"]).To(Equal("not_found"))
+			Expect(body["detail"]).To(Equal("product not found"))
+
+		})
+
+	})
+
+	Context("Request context", func() {
+
+		It("Passes the context of the request to the service", func() {
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			service := &fakeService{getByID: func(ctx context.Context, id string) (*domain.Product, error) {
+				Expect(ctx.Err()).To(MatchError(context.Canceled))
+				return nil, product.NewError(product.KindUnavailable, "the request was canceled before the products could be accessed", ctx.Err())
+			}}
+
+			recorder := serve(service, request(http.MethodGet, "/products/1", nil).WithContext(ctx))
+
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(problem(recorder)["code"]).To(Equal("unavailable"))
+
+		})
+
+	})
+
+})
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"MicroserviceTemplate/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+	"time"
+)
+
+var _ = Describe("Application configuration", func() {
+
+	BeforeEach(func() {
+		resetConfiguration()
+		viper.Set("application.name", "ms-template-mongo-go")
+		viper.Set("application.config.import", "http://localhost:8888")
+		viper.Set("application.config.profile", "default")
+		viper.Set("pagination.token-secret", "secret")
+	})
+
+	It("Binds the configuration with its defaults", func() {
+
+		viper.Set("keycloak.url", "http://localhost:8180")
+		viper.Set("keycloak.realm", "master")
+		viper.Set("server.shutdown-timeout", "30s")
+
+		appConfig, err := config.NewAppConfig()
+
+		Expect(err).To(BeNil())
+		Expect(appConfig.Server.ShutdownTimeout).To(Equal(30 * time.Second))
+		Expect(appConfig.Database.Host).To(Equal("localhost"))
+		Expect(appConfig.Database.Port).To(Equal(27017))
+		Expect(appConfig.Eureka.Client.ServiceURL.DefaultZone).To(Equal("http://localhost:8761/eureka"))
+
+	})
+
+	It("Binds the settings that used to be read from viper", func() {
+
+		viper.Set("keycloak.url", "http://localhost:8180")
+		viper.Set("keycloak.realm", "master")
+		viper.Set("database.timeouts.find-one", "2s")
+		viper.Set("security.excluded-paths", []string{"/public/**"})
+		viper.Set("application.config.refresh.interval", "1m")
+
+		appConfig, err := config.NewAppConfig()
+
+		Expect(err).To(BeNil())
+		Expect(appConfig.Database.Timeouts.FindOne).To(Equal(2 * time.Second))
+		Expect(appConfig.Database.Timeouts.Default).To(Equal(5 * time.Second))
+		Expect(appConfig.Management.Health.Timeout).To(Equal(5 * time.Second))
+		Expect(appConfig.Security.ExcludedPaths).To(Equal([]string{"/public/**"}))
+		Expect(appConfig.Application.Config.Refresh.Interval).To(Equal(time.Minute))
+		Expect(appConfig.Application.Config.Timeout).To(Equal(10 * time.Second))
+		Expect(appConfig.Application.Config.Retry.MaxAttempts).To(Equal(6))
+
+	})
+
+	It("Lists every missing or invalid key at once", func() {
+
+		viper.Set("keycloak.url", "not a url")
+		viper.Set("database.port", 70000)
+		viper.Set("database.username", "admin")
+		viper.Set("pagination.token-secret", "")
+
+		_, err := config.NewAppConfig()
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("keycloak.url must be a URL"))
+		Expect(err.Error()).To(ContainSubstring("keycloak.realm is required"))
+		Expect(err.Error()).To(ContainSubstring("database.port must be lte 65535"))
+		Expect(err.Error()).To(ContainSubstring("database.password is required when username is set"))
+		Expect(err.Error()).To(ContainSubstring("pagination.token-secret is required"))
+
+	})
+
+})
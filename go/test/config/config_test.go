@@ -0,0 +1,455 @@
+package config
+
+import (
+	"MicroserviceTemplate/config"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/pbkdf2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Config Suite")
+}
+
+// cacheDir holds the configuration caches written by the specs
+var cacheDir string
+
+var _ = BeforeSuite(func() {
+	var err error
+	cacheDir, err = os.MkdirTemp("", "config-test")
+	Expect(err).To(BeNil())
+})
+
+var _ = AfterSuite(func() {
+	_ = os.RemoveAll(cacheDir)
+})
+
+// * ============
+
+// resetConfiguration clears the configuration and caches it in a new temporary directory
+func resetConfiguration() {
+
+	viper.Reset()
+	Expect(config.LoadCommandLine(nil)).To(Succeed())
+
+	dir, err := os.MkdirTemp(cacheDir, "config-cache")
+	Expect(err).To(BeNil())
+
+	viper.Set("application.config.cache.dir", dir)
+
+}
+
+// * ============
+
+// configServer returns a fake Spring Cloud Config server that always answers with the body
+func configServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+var _ = Describe("Spring Cloud Config", func() {
+
+	BeforeEach(func() {
+		resetConfiguration()
+	})
+
+	It("Merges every property source with the first one winning", func() {
+
+		server := configServer(`{
+			"name": "ms-template-mongo-go",
+			"profiles": ["dev"],
+			"propertySources": [
+				{"name": "ms-template-mongo-go-dev.yml", "source": {"database.host": "mongo-dev"}},
+				{"name": "ms-template-mongo-go.yml", "source": {"database.host": "mongo", "database.port": "27018"}},
+				{"name": "application.yml", "source": {"database.name": "shared", "keycloak.realm": "master"}}
+			]
+		}`)
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "dev", "main")
+
+		Expect(viper.GetString("database.host")).To(Equal("mongo-dev"))
+		Expect(viper.GetString("database.port")).To(Equal("27018"))
+		Expect(viper.GetString("database.name")).To(Equal("shared"))
+		Expect(viper.GetString("keycloak.realm")).To(Equal("master"))
+
+	})
+
+	It("Expands indexed keys into lists", func() {
+
+		server := configServer(`{
+			"name": "ms-template-mongo-go",
+			"propertySources": [
+				{"name": "ms-template-mongo-go.yml", "source": {
+					"security.excluded-paths[0]": "/swagger/*any",
+					"security.excluded-paths[1]": "/health/**",
+					"routes[0].id": "pricing",
+					"routes[0].uri": "lb://pricing-service",
+					"routes[1].id": "orders"
+				}}
+			]
+		}`)
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+
+		Expect(viper.GetStringSlice("security.excluded-paths")).To(Equal([]string{"/swagger/*any", "/health/**"}))
+
+		routes, ok := viper.Get("routes").([]interface{})
+		Expect(ok).To(BeTrue())
+		Expect(routes).To(HaveLen(2))
+		Expect(routes[0]).To(HaveKeyWithValue("uri", "lb://pricing-service"))
+		Expect(routes[1]).To(HaveKeyWithValue("id", "orders"))
+
+	})
+
+	It("Replaces lists from lower precedence sources as a whole", func() {
+
+		server := configServer(`{
+			"name": "ms-template-mongo-go",
+			"propertySources": [
+				{"name": "ms-template-mongo-go.yml", "source": {"security.excluded-paths[0]": "/metrics"}},
+				{"name": "application.yml", "source": {"security.excluded-paths[0]": "/swagger/*any", "security.excluded-paths[1]": "/health/**"}}
+			]
+		}`)
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+
+		Expect(viper.GetStringSlice("security.excluded-paths")).To(Equal([]string{"/metrics"}))
+
+	})
+
+	It("Keeps the local configuration when there are no property sources", func() {
+
+		viper.Set("database.host", "localhost")
+
+		server := configServer(`{"name": "ms-template-mongo-go", "propertySources": []}`)
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+
+		Expect(viper.GetString("database.host")).To(Equal("localhost"))
+
+	})
+
+})
+
+var _ = Describe("Configuration refresh", func() {
+
+	BeforeEach(func() {
+		resetConfiguration()
+	})
+
+	It("Publishes the changed and removed keys to the listeners of their prefix", func() {
+
+		body := `{"name": "ms-template-mongo-go", "propertySources": [
+			{"name": "ms-template-mongo-go.yml", "source": {"database.timeouts.find": "2s", "database.pool.max-size": 50, "keycloak.realm": "master"}}
+		]}`
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}))
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+
+		refresher := config.NewRefresher()
+
+		var received []config.Change
+		refresher.Subscribe("database", func(changes []config.Change) {
+			received = changes
+		})
+
+		body = `{"name": "ms-template-mongo-go", "propertySources": [
+			{"name": "ms-template-mongo-go.yml", "source": {"database.timeouts.find": "3s", "keycloak.realm": "master"}}
+		]}`
+
+		changes, err := refresher.Refresh()
+
+		Expect(err).To(BeNil())
+		Expect(changes).To(HaveLen(2))
+		Expect(received).To(Equal([]config.Change{
+			{Key: "database.pool.max-size", OldValue: float64(50), NewValue: nil},
+			{Key: "database.timeouts.find", OldValue: "2s", NewValue: "3s"},
+		}))
+		Expect(viper.GetDuration("database.timeouts.find")).To(Equal(3 * time.Second))
+		Expect(viper.IsSet("database.pool.max-size")).To(BeFalse())
+
+	})
+
+	It("Subscribes and stops polling while a refresh waits for the config server", func() {
+
+		body := `{"name": "ms-template-mongo-go", "propertySources": []}`
+		fetching, release := make(chan struct{}), make(chan struct{})
+		var refreshing int32
+		var once sync.Once
+
+		// The config server answers the startup at once and holds the refresh
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&refreshing) == 1 {
+				once.Do(func() { close(fetching) })
+				<-release
+			}
+			_, _ = w.Write([]byte(body))
+		}))
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+		atomic.StoreInt32(&refreshing, 1)
+
+		refresher := config.NewRefresher()
+		refresher.StartPolling(time.Hour)
+
+		// The refresh is waited for, so it does not load the configuration while the next spec runs
+		refreshed := make(chan struct{})
+		go func() {
+			defer close(refreshed)
+			_, _ = refresher.Refresh()
+		}()
+		defer func() {
+			close(release)
+			Eventually(refreshed).Should(BeClosed())
+		}()
+		Eventually(fetching).Should(BeClosed())
+
+		unblocked := make(chan struct{})
+		go func() {
+			refresher.Subscribe("database", func(changes []config.Change) {})
+			refresher.StopPolling()
+			close(unblocked)
+		}()
+
+		Eventually(unblocked, time.Second).Should(BeClosed())
+
+	})
+
+})
+
+var _ = Describe("Config server client", func() {
+
+	BeforeEach(func() {
+		resetConfiguration()
+		viper.Set("application.config.retry.initial-interval", time.Millisecond)
+		viper.Set("application.config.retry.max-interval", 5*time.Millisecond)
+	})
+
+	It("Retries while the config server is unavailable and sends the basic credentials", func() {
+
+		viper.Set("application.config.username", "config")
+		viper.Set("application.config.password", "secret")
+
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			requests++
+
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "config" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if requests < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"name": "ms-template-mongo-go", "propertySources": [
+				{"name": "ms-template-mongo-go.yml", "source": {"database.host": "mongo"}}
+			]}`))
+
+		}))
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+
+		Expect(requests).To(Equal(3))
+		Expect(viper.GetString("database.host")).To(Equal("mongo"))
+
+	})
+
+	It("Does not retry client errors and starts with the local configuration when fail-fast is disabled", func() {
+
+		viper.Set("application.config.token", "expired")
+		viper.Set("database.host", "localhost")
+
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			Expect(r.Header.Get("Authorization")).To(Equal("Bearer expired"))
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+
+		Expect(requests).To(Equal(1))
+		Expect(viper.GetString("database.host")).To(Equal("localhost"))
+
+	})
+
+})
+
+// springEncrypt encrypts the value like Spring Security's Encryptors.standard: iv + AES-256-CBC(value) with the key
+// derived by PBKDF2WithHmacSHA1 from the password and the salt deadbeef
+func springEncrypt(password string, value string) []byte {
+
+	salt, _ := hex.DecodeString("deadbeef")
+	block, _ := aes.NewCipher(pbkdf2.Key([]byte(password), salt, 1024, 32, sha1.New))
+
+	padding := aes.BlockSize - len(value)%aes.BlockSize
+	plain := append([]byte(value), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	data := make([]byte, aes.BlockSize+len(plain))
+	_, _ = rand.Read(data[:aes.BlockSize])
+	cipher.NewCBCEncrypter(block, data[:aes.BlockSize]).CryptBlocks(data[aes.BlockSize:], plain)
+
+	return data
+
+}
+
+var _ = Describe("Encrypted values", func() {
+
+	BeforeEach(func() {
+		resetConfiguration()
+	})
+
+	It("Decrypts the values encrypted with a symmetric key and keeps the plain ones", func() {
+
+		viper.Set("encrypt.key", "my-symmetric-key")
+
+		password := hex.EncodeToString(springEncrypt("my-symmetric-key", "s3cr3t"))
+
+		server := configServer(`{"name": "ms-template-mongo-go", "propertySources": [
+			{"name": "ms-template-mongo-go.yml", "source": {"database.password": "{cipher}` + password + `", "database.username": "admin"}}
+		]}`)
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+
+		Expect(viper.GetString("database.password")).To(Equal("s3cr3t"))
+		Expect(viper.GetString("database.username")).To(Equal("admin"))
+
+	})
+
+	It("Decrypts the values encrypted with an RSA key pair", func() {
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).To(BeNil())
+
+		viper.Set("encrypt.key", string(pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		})))
+
+		secret := make([]byte, 8)
+		_, _ = rand.Read(secret)
+
+		encryptedSecret, err := rsa.EncryptPKCS1v15(rand.Reader, &privateKey.PublicKey, secret)
+		Expect(err).To(BeNil())
+
+		data := []byte{byte(len(encryptedSecret) >> 8), byte(len(encryptedSecret))}
+		data = append(data, encryptedSecret...)
+		data = append(data, springEncrypt(hex.EncodeToString(secret), "s3cr3t")...)
+
+		server := configServer(`{"name": "ms-template-mongo-go", "propertySources": [
+			{"name": "ms-template-mongo-go.yml", "source": {"database.password": "{cipher}` + base64.StdEncoding.EncodeToString(data) + `"}}
+		]}`)
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+
+		Expect(viper.GetString("database.password")).To(Equal("s3cr3t"))
+
+	})
+
+})
+
+var _ = Describe("Last known good configuration", func() {
+
+	BeforeEach(func() {
+		resetConfiguration()
+		viper.Set("application.config.retry.max-attempts", 1)
+	})
+
+	It("Starts with the cached configuration when the config server is unreachable", func() {
+
+		server := configServer(`{"name": "ms-template-mongo-go", "version": "a1b2c3", "propertySources": [
+			{"name": "ms-template-mongo-go.yml", "source": {"database.host": "mongo"}}
+		]}`)
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+		server.Close()
+
+		cacheDir := viper.GetString("application.config.cache.dir")
+		viper.Reset()
+		viper.Set("application.config.cache.dir", cacheDir)
+		viper.Set("application.config.retry.max-attempts", 1)
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+
+		Expect(viper.GetString("database.host")).To(Equal("mongo"))
+
+	})
+
+	It("Does not contact the config server in offline mode", func() {
+
+		viper.Set("application.config.offline", true)
+
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+		}))
+		defer server.Close()
+
+		config.LoadConfigurationFromBranch(server.URL, "ms-template-mongo-go", "default", "main")
+
+		_, err := config.NewRefresher().Refresh()
+
+		Expect(requests).To(Equal(0))
+		Expect(err).To(HaveOccurred())
+
+	})
+
+	It("Signs the continuation tokens with a random secret in offline mode when none is set", func() {
+
+		viper.Set("application.config.offline", true)
+
+		config.LoadConfigurationFromBranch("http://localhost:8888", "ms-template-mongo-go", "default", "main")
+		generated := viper.GetString("pagination.token-secret")
+
+		Expect(generated).To(HaveLen(64))
+
+		resetConfiguration()
+		viper.Set("application.config.offline", true)
+		viper.Set("pagination.token-secret", "shared")
+
+		config.LoadConfigurationFromBranch("http://localhost:8888", "ms-template-mongo-go", "default", "main")
+
+		Expect(viper.GetString("pagination.token-secret")).To(Equal("shared"))
+
+	})
+
+})
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"MicroserviceTemplate/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+)
+
+// writeFile writes the content to the file of the directory
+func writeFile(dir string, name string, content string) {
+	Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)).To(Succeed())
+}
+
+var _ = Describe("Layered configuration", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+
+		resetConfiguration()
+
+		var err error
+		dir, err = os.MkdirTemp(cacheDir, "resources")
+		Expect(err).To(BeNil())
+
+		writeFile(dir, "application.yml", `
+application:
+  config:
+    profile: dev,k8s
+database:
+  host: localhost
+  name: products
+  url: mongodb://${database.host}:${DB_PORT:27017}/${database.name}
+`)
+		writeFile(dir, "application-dev.yml", "database:\n  host: mongo-dev\n  name: products-dev\n")
+		writeFile(dir, "application-k8s.yml", "database:\n  host: mongo-k8s\n")
+
+	})
+
+	It("Lays the profile files over the base file with the last profile winning", func() {
+
+		Expect(config.LoadLocalConfiguration(dir)).To(Succeed())
+
+		Expect(config.ActiveProfiles()).To(Equal([]string{"dev", "k8s"}))
+		Expect(viper.GetString("database.host")).To(Equal("mongo-k8s"))
+		Expect(viper.GetString("database.name")).To(Equal("products-dev"))
+		Expect(viper.GetString("database.url")).To(Equal("mongodb://mongo-k8s:27017/products-dev"))
+
+	})
+
+	It("Overrides the files with the environment and the environment with the command line", func() {
+
+		Expect(os.Setenv("DATABASE_HOST", "mongo-env")).To(Succeed())
+		Expect(os.Setenv("DATABASE_NAME", "products-env")).To(Succeed())
+		Expect(os.Setenv("DB_PORT", "27018")).To(Succeed())
+		defer func() {
+			_ = os.Unsetenv("DATABASE_HOST")
+			_ = os.Unsetenv("DATABASE_NAME")
+			_ = os.Unsetenv("DB_PORT")
+		}()
+
+		Expect(config.LoadCommandLine([]string{"serve", "--database.name=products-flag", "--application.config.profile", "dev"})).To(Succeed())
+		Expect(config.LoadLocalConfiguration(dir)).To(Succeed())
+
+		Expect(config.ActiveProfiles()).To(Equal([]string{"dev"}))
+		Expect(viper.GetString("database.host")).To(Equal("mongo-env"))
+		Expect(viper.GetString("database.name")).To(Equal("products-flag"))
+		Expect(viper.GetString("database.url")).To(Equal("mongodb://mongo-env:27018/products-flag"))
+
+	})
+
+})
@@ -0,0 +1,83 @@
+package product
+
+import (
+	"MicroserviceTemplate/internal/domain"
+	"MicroserviceTemplate/internal/product"
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// failingRepository fails every read by ID with its error
+type failingRepository struct {
+	product.IRepository
+	err error
+}
+
+func (fr *failingRepository) GetByID(_ context.Context, _ string) (*domain.Product, error) {
+	return nil, fr.err
+}
+
+// mongoErrors returns the number of errors of the kind counted for the repository method
+func mongoErrors(method string, kind string) float64 {
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, family := range families {
+
+		if family.GetName() != "mongo_operation_errors_total" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels["method"] == method && labels["kind"] == kind {
+				return metric.GetCounter().GetValue()
+			}
+
+		}
+
+	}
+
+	return 0
+
+}
+
+var _ = Describe("Instrumented repository", func() {
+
+	ctx := context.Background()
+
+	It("Does not count a product not found as a MongoDB error", func() {
+
+		repository := product.NewInstrumentedRepository(&failingRepository{err: product.ErrNotFound})
+
+		before := mongoErrors("GetByID", string(product.KindNotFound))
+
+		_, err := repository.GetByID(ctx, "missing")
+
+		Expect(err).To(MatchError(product.ErrNotFound))
+		Expect(mongoErrors("GetByID", string(product.KindNotFound))).To(Equal(before))
+
+	})
+
+	It("Counts the failures of MongoDB by kind", func() {
+
+		repository := product.NewInstrumentedRepository(&failingRepository{err: product.ErrUnavailable})
+
+		before := mongoErrors("GetByID", string(product.KindUnavailable))
+
+		_, err := repository.GetByID(ctx, "any")
+
+		Expect(err).To(HaveOccurred())
+		Expect(mongoErrors("GetByID", string(product.KindUnavailable))).To(Equal(before + 1))
+
+	})
+
+})
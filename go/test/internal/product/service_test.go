@@ -1,13 +1,18 @@
 package product
 
 import (
+	"MicroserviceTemplate/config"
 	"MicroserviceTemplate/internal/domain"
 	"MicroserviceTemplate/internal/product"
+	"MicroserviceTemplate/pkg/pagination"
 	store "MicroserviceTemplate/pkg/store/product"
+	"context"
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestTest(t *testing.T) {
@@ -26,13 +31,32 @@ var (
 
 var _ = Describe("Product Service", func() {
 
-	productStore := store.NewStore()
+	productStore := store.NewStore(config.DatabaseConfig{Name: "microservice_go_template", Host: "localhost", Port: 27017})
 	productRepository := product.NewRepository(productStore)
-	productService := product.NewService(productRepository)
+	productService := product.NewService(productRepository, pagination.NewTokenCodec(config.PaginationConfig{TokenSecret: "test-secret"}))
+	ctx := context.Background()
+
+	// The service is tested against a real MongoDB, the specs are skipped where there is none
+	var ping sync.Once
+	var pingErr error
+
+	BeforeEach(func() {
+
+		ping.Do(func() {
+			pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			pingErr = productStore.Ping(pingCtx)
+		})
+
+		if pingErr != nil {
+			Skip("MongoDB is not reachable on localhost:27017: " + pingErr.Error())
+		}
+
+	})
 
 	It("Save product", func() {
 
-		product, err := productService.Save(&productTest)
+		product, err := productService.Save(ctx, &productTest)
 
 		Expect(err).To(BeNil())
 		Expect(err).NotTo(HaveOccurred())
@@ -45,22 +69,26 @@ var _ = Describe("Product Service", func() {
 
 	It("Read all products", func() {
 
-		products, err := productService.GetAll()
+		query := domain.NewProductQuery()
+		query.NamePrefix = productTest.Name
+
+		page, err := productService.GetAll(ctx, query)
 
 		Expect(err).To(BeNil())
 		Expect(err).NotTo(HaveOccurred())
 
-		Expect(len(*products) > 0).To(BeTrue())
+		Expect(len(page.Items) > 0).To(BeTrue())
+		Expect(page.Total > 0).To(BeTrue())
 
-		Expect((*products)[0].Name).To(Equal(productTest.Name))
-		Expect((*products)[0].Price).To(Equal(productTest.Price))
-		Expect((*products)[0].Quantity).To(Equal(productTest.Quantity))
+		Expect(page.Items[0].Name).To(Equal(productTest.Name))
+		Expect(page.Items[0].Price).To(Equal(productTest.Price))
+		Expect(page.Items[0].Quantity).To(Equal(productTest.Quantity))
 
 	})
 
 	It("Read product by id", func() {
 
-		product, err := productService.GetByID(productTest.ID)
+		product, err := productService.GetByID(ctx, productTest.ID)
 
 		Expect(err).To(BeNil())
 		Expect(err).NotTo(HaveOccurred())
@@ -77,12 +105,12 @@ var _ = Describe("Product Service", func() {
 		productTest.Price = 2.0
 		productTest.Quantity = 2
 
-		err := productService.Update(&productTest)
+		_, err := productService.Update(ctx, &productTest, product.AnyVersion)
 
 		Expect(err).To(BeNil())
 		Expect(err).NotTo(HaveOccurred())
 
-		product, err := productService.GetByID(productTest.ID)
+		product, err := productService.GetByID(ctx, productTest.ID)
 
 		Expect(err).To(BeNil())
 		Expect(err).NotTo(HaveOccurred())
@@ -95,12 +123,12 @@ var _ = Describe("Product Service", func() {
 
 	It("Delete product", func() {
 
-		err := productService.Delete(productTest.ID)
+		err := productService.Delete(ctx, productTest.ID, product.AnyVersion)
 
 		Expect(err).To(BeNil())
 		Expect(err).NotTo(HaveOccurred())
 
-		_, err = productService.GetByID(productTest.ID)
+		_, err = productService.GetByID(ctx, productTest.ID)
 
 		Expect(err).To(HaveOccurred())
 
@@ -0,0 +1,128 @@
+package eureka
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/eureka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Eureka Suite")
+}
+
+// eurekaConfig returns the configuration of a client of the Eureka server
+func eurekaConfig(url string) config.EurekaConfig {
+
+	var eurekaConfig config.EurekaConfig
+
+	eurekaConfig.Client.ServiceURL.DefaultZone = url
+	eurekaConfig.Client.RegistryFetchIntervalSeconds = 30
+	eurekaConfig.Client.FilterOnlyUpInstances = true
+
+	return eurekaConfig
+
+}
+
+var _ = Describe("Discovery client", func() {
+
+	var apps, delta string
+	var fullFetches int
+	var server *httptest.Server
+
+	BeforeEach(func() {
+
+		fullFetches = 0
+
+		apps = `{"applications": {"versions__delta": "1", "apps__hashcode": "DOWN_1_UP_1_", "application": [
+			{"name": "PRICING-SERVICE", "instance": [
+				{"instanceId": "pricing-1", "app": "PRICING-SERVICE", "hostName": "10.0.0.1", "status": "UP", "port": {"$": 8080, "@enabled": "true"}},
+				{"instanceId": "pricing-2", "app": "PRICING-SERVICE", "hostName": "10.0.0.2", "status": "DOWN", "port": {"$": 8080, "@enabled": "true"}}
+			]}
+		]}}`
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/eureka/apps":
+				fullFetches++
+				_, _ = w.Write([]byte(apps))
+			case "/eureka/apps/delta":
+				_, _ = w.Write([]byte(delta))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Returns only the UP instances of the application", func() {
+
+		client := eureka.NewDiscoveryClient(eurekaConfig(server.URL + "/eureka"))
+
+		Expect(client.Refresh()).To(Succeed())
+
+		instances := client.GetInstances("pricing-service")
+		Expect(instances).To(HaveLen(1))
+		Expect(instances[0].InstanceId).To(Equal("pricing-1"))
+		Expect(client.GetApplications()).To(Equal([]string{"PRICING-SERVICE"}))
+
+	})
+
+	It("Applies the deltas that match the hash of the server", func() {
+
+		client := eureka.NewDiscoveryClient(eurekaConfig(server.URL + "/eureka"))
+		Expect(client.Refresh()).To(Succeed())
+
+		// A single application and instance are sent as objects instead of lists
+		delta = `{"applications": {"versions__delta": "2", "apps__hashcode": "UP_2_", "application": {
+			"name": "PRICING-SERVICE", "instance": {
+				"instanceId": "pricing-2", "app": "PRICING-SERVICE", "hostName": "10.0.0.2", "status": "UP", "actionType": "MODIFIED"
+			}
+		}}}`
+
+		Expect(client.Refresh()).To(Succeed())
+
+		Expect(client.GetInstances("PRICING-SERVICE")).To(HaveLen(2))
+		Expect(fullFetches).To(Equal(1))
+
+	})
+
+	It("Fetches the whole registry when the hash does not match after a delta", func() {
+
+		client := eureka.NewDiscoveryClient(eurekaConfig(server.URL + "/eureka"))
+		Expect(client.Refresh()).To(Succeed())
+
+		// The delta misses an instance, so the local cache ends up with UP_1_ instead of UP_2_
+		delta = `{"applications": {"versions__delta": "2", "apps__hashcode": "UP_2_", "application": [
+			{"name": "PRICING-SERVICE", "instance": [{"instanceId": "pricing-2", "app": "PRICING-SERVICE", "status": "DOWN", "actionType": "DELETED"}]}
+		]}}`
+		apps = `{"applications": {"versions__delta": "3", "apps__hashcode": "UP_2_", "application": [
+			{"name": "PRICING-SERVICE", "instance": [{"instanceId": "pricing-1", "app": "PRICING-SERVICE", "hostName": "10.0.0.1", "status": "UP"}]},
+			{"name": "ORDER-SERVICE", "instance": [{"instanceId": "order-1", "app": "ORDER-SERVICE", "hostName": "10.0.0.3", "status": "UP"}]}
+		]}}`
+
+		Expect(client.Refresh()).To(Succeed())
+
+		Expect(fullFetches).To(Equal(2))
+		Expect(client.GetInstances("PRICING-SERVICE")).To(HaveLen(1))
+		Expect(client.GetInstances("ORDER-SERVICE")).To(HaveLen(1))
+
+	})
+
+	It("Hashes the instances like the Eureka server, by status in alphabetical order", func() {
+
+		Expect(eureka.HashCode(nil)).To(BeEmpty())
+		Expect(eureka.HashCode([]eureka.InstanceDetails{{Status: "UP"}, {Status: "DOWN"}, {Status: "UP"}, {Status: "UP"}})).To(Equal("DOWN_1_UP_3_"))
+
+	})
+
+})
@@ -0,0 +1,97 @@
+package eurekatest
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/eureka"
+	"MicroserviceTemplate/pkg/eureka/eurekatest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"testing"
+	"time"
+)
+
+func TestTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fake Eureka Server Suite")
+}
+
+var _ = Describe("Fake Eureka server", func() {
+
+	var server *eurekatest.Server
+	var eurekaConfig config.EurekaConfig
+
+	BeforeEach(func() {
+
+		server = eurekatest.NewServer()
+
+		eurekaConfig = config.EurekaConfig{}
+		eurekaConfig.Client.ServiceURL.DefaultZone = server.URL()
+		eurekaConfig.Client.RegistryFetchIntervalSeconds = 30
+		eurekaConfig.Client.FilterOnlyUpInstances = true
+		eurekaConfig.Instance.IPAddress = "10.0.0.7"
+		eurekaConfig.Instance.LeaseRenewalIntervalInSeconds = 30
+		eurekaConfig.Instance.LeaseExpirationDurationInSeconds = 90
+
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Expires the leases that are not renewed and lets the client register again", func() {
+
+		client := eureka.NewClient(eurekaConfig, config.ServerConfig{})
+
+		Expect(client.RegisterApp("PRICING-SERVICE", "pricing-1", 8080)).To(Succeed())
+		Expect(client.Renew("PRICING-SERVICE", "pricing-1", 8080)).To(Succeed())
+
+		instance, ok := server.Instance("pricing-service", "pricing-1")
+		Expect(ok).To(BeTrue())
+		Expect(instance.IpAddr).To(Equal("10.0.0.7"))
+
+		server.Advance(2 * time.Minute)
+
+		_, ok = server.Instance("PRICING-SERVICE", "pricing-1")
+		Expect(ok).To(BeFalse())
+
+		// The heartbeat finds the lease expired and registers the instance again
+		Expect(client.Renew("PRICING-SERVICE", "pricing-1", 8080)).To(Succeed())
+
+		Expect(server.Instances("PRICING-SERVICE")).To(HaveLen(1))
+		Expect(server.Registrations("pricing-1")).To(Equal(2))
+		Expect(server.Heartbeats("pricing-1")).To(Equal(2))
+
+	})
+
+	It("Serves the registry and its deltas to the discovery client", func() {
+
+		client := eureka.NewClient(eurekaConfig, config.ServerConfig{})
+		discovery := eureka.NewDiscoveryClient(eurekaConfig)
+
+		Expect(client.UpdateAppStatus("PRICING-SERVICE", "pricing-1", 8080, eureka.StatusUp)).To(Succeed())
+		Expect(discovery.Refresh()).To(Succeed())
+		Expect(discovery.GetInstances("PRICING-SERVICE")).To(HaveLen(1))
+
+		Expect(client.UpdateAppStatus("PRICING-SERVICE", "pricing-2", 8081, eureka.StatusUp)).To(Succeed())
+		Expect(client.DeleteApp("PRICING-SERVICE", "pricing-1")).To(Succeed())
+		Expect(discovery.Refresh()).To(Succeed())
+
+		instances := discovery.GetInstances("PRICING-SERVICE")
+		Expect(instances).To(HaveLen(1))
+		Expect(instances[0].InstanceId).To(Equal("pricing-2"))
+
+	})
+
+	It("Answers 503 while it is unavailable", func() {
+
+		client := eureka.NewClient(eurekaConfig, config.ServerConfig{})
+
+		server.SetAvailable(false)
+		Expect(client.RegisterApp("PRICING-SERVICE", "pricing-1", 8080)).To(HaveOccurred())
+
+		server.SetAvailable(true)
+		Expect(client.RegisterApp("PRICING-SERVICE", "pricing-1", 8080)).To(Succeed())
+
+	})
+
+})
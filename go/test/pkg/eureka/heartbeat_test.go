@@ -0,0 +1,86 @@
+package eureka
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/eureka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+var _ = Describe("Heartbeats", func() {
+
+	It("Registers the instance again when the Eureka server no longer knows it", func() {
+
+		var mu sync.Mutex
+		registrations, heartbeats := 0, 0
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch r.Method {
+			case http.MethodPost:
+				registrations++
+				w.WriteHeader(http.StatusNoContent)
+			case http.MethodPut:
+				heartbeats++
+				// The first heartbeat finds the instance evicted
+				if heartbeats == 1 {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+
+		}))
+		defer server.Close()
+
+		var eurekaConfig config.EurekaConfig
+		eurekaConfig.Client.ServiceURL.DefaultZone = server.URL + "/eureka"
+		eurekaConfig.Instance.LeaseRenewalIntervalInSeconds = 1
+
+		client := eureka.NewClient(eurekaConfig, config.ServerConfig{Hostname: "localhost"})
+		client.StartClient("PRICING-SERVICE", "pricing-1", 8080)
+
+		// Registration and UP at start, plus the registration after the 404
+		Eventually(func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return registrations
+		}, 5*time.Second, 50*time.Millisecond).Should(Equal(3))
+
+		Expect(client.HeartbeatStatus().Reregistrations).To(Equal(1))
+		Expect(client.HeartbeatStatus().ConsecutiveFailures).To(Equal(0))
+
+	})
+
+	It("Keeps running and counts the failures while the Eureka server is unreachable", func() {
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		var eurekaConfig config.EurekaConfig
+		eurekaConfig.Client.ServiceURL.DefaultZone = server.URL + "/eureka"
+		eurekaConfig.Instance.LeaseRenewalIntervalInSeconds = 1
+
+		client := eureka.NewClient(eurekaConfig, config.ServerConfig{Hostname: "localhost"})
+		client.StartClient("PRICING-SERVICE", "pricing-1", 8080)
+
+		Eventually(func() int {
+			return client.HeartbeatStatus().ConsecutiveFailures
+		}, 5*time.Second, 50*time.Millisecond).Should(BeNumerically(">=", 1))
+
+		Expect(client.HeartbeatStatus().LastError).To(ContainSubstring("503"))
+
+	})
+
+})
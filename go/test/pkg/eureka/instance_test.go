@@ -0,0 +1,79 @@
+package eureka
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/eureka"
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Eureka instance", func() {
+
+	var registered eureka.AppRegistrationBody
+	var server *httptest.Server
+
+	BeforeEach(func() {
+
+		registered = eureka.AppRegistrationBody{}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&registered)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Registers the listener port, the IP address, the lease and the metadata", func() {
+
+		var eurekaConfig config.EurekaConfig
+		eurekaConfig.Client.ServiceURL.DefaultZone = server.URL + "/eureka"
+		eurekaConfig.Instance.IPAddress = "10.0.0.7"
+		eurekaConfig.Instance.PreferIPAddress = true
+		eurekaConfig.Instance.LeaseRenewalIntervalInSeconds = 10
+		eurekaConfig.Instance.LeaseExpirationDurationInSeconds = 30
+		eurekaConfig.Instance.MetadataMap = map[string]string{"zone": "zone-a", "version": "1.4.0"}
+
+		client := eureka.NewClient(eurekaConfig, config.ServerConfig{})
+
+		Expect(client.RegisterApp("PRICING-SERVICE", "pricing-1", 43125)).To(Succeed())
+
+		instance := registered.Instance
+		Expect(instance.HostName).To(Equal("10.0.0.7"))
+		Expect(instance.IpAddr).To(Equal("10.0.0.7"))
+		Expect(instance.Port).To(Equal(eureka.Port{Port: 43125, Enabled: "true"}))
+		Expect(instance.SecurePort.Enabled).To(Equal("false"))
+		Expect(instance.HealthCheckUrl).To(Equal("http://10.0.0.7:43125/health"))
+		Expect(instance.LeaseInfo).To(Equal(eureka.LeaseInfo{RenewalIntervalInSecs: 10, DurationInSecs: 30}))
+		Expect(instance.Metadata).To(HaveKeyWithValue(eureka.MetadataZone, "zone-a"))
+		Expect(instance.Metadata).To(HaveKeyWithValue(eureka.MetadataVersion, "1.4.0"))
+		Expect(instance.Metadata).To(HaveKeyWithValue(eureka.MetadataManagementPort, "43125"))
+
+	})
+
+	It("Enables only the secure port when TLS is on", func() {
+
+		var eurekaConfig config.EurekaConfig
+		eurekaConfig.Client.ServiceURL.DefaultZone = server.URL + "/eureka"
+		eurekaConfig.Instance.Hostname = "pricing.internal"
+		eurekaConfig.Instance.IPAddress = "10.0.0.7"
+
+		client := eureka.NewClient(eurekaConfig, config.ServerConfig{SSL: config.SSLConfig{Enabled: true}})
+
+		Expect(client.RegisterApp("PRICING-SERVICE", "pricing-1", 8443)).To(Succeed())
+
+		instance := registered.Instance
+		Expect(instance.HostName).To(Equal("pricing.internal"))
+		Expect(instance.Port.Enabled).To(Equal("false"))
+		Expect(instance.SecurePort).To(Equal(eureka.Port{Port: 8443, Enabled: "true"}))
+		Expect(instance.HomePageUrl).To(Equal("https://pricing.internal:8443"))
+
+	})
+
+})
@@ -0,0 +1,74 @@
+package eureka
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/eureka"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+var _ = Describe("Eureka peers", func() {
+
+	It("Tries the servers of the zone of the instance first", func() {
+
+		var eurekaConfig config.EurekaConfig
+		eurekaConfig.Client.Region = "eu-west-1"
+		eurekaConfig.Client.AvailabilityZones = map[string]string{"eu-west-1": "zone-a,zone-b"}
+		eurekaConfig.Client.PreferSameZoneEureka = true
+		eurekaConfig.Client.ServiceURL.DefaultZone = "http://default:8761/eureka"
+		eurekaConfig.Client.ServiceURL.Zones = map[string]string{
+			"zone-a": "http://peer1:8761/eureka/,http://peer2:8761/eureka",
+			"zone-b": "http://peer3:8761/eureka",
+		}
+		eurekaConfig.Instance.MetadataMap = map[string]string{"zone": "zone-b"}
+
+		Expect(eureka.ServiceURLs(eurekaConfig)).To(Equal([]string{
+			"http://peer3:8761/eureka",
+			"http://peer1:8761/eureka",
+			"http://peer2:8761/eureka",
+		}))
+
+	})
+
+	It("Fails over to the next server and sticks to it", func() {
+
+		var mu sync.Mutex
+		requests := map[string]int{}
+
+		handler := func(name string, status int) *httptest.Server {
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests[name]++
+				mu.Unlock()
+				w.WriteHeader(status)
+			}))
+		}
+
+		peer1 := handler("peer1", http.StatusServiceUnavailable)
+		defer peer1.Close()
+		peer2 := handler("peer2", http.StatusNoContent)
+		defer peer2.Close()
+		peer3 := handler("peer3", http.StatusNoContent)
+		defer peer3.Close()
+
+		var eurekaConfig config.EurekaConfig
+		eurekaConfig.Client.ServiceURL.DefaultZone = peer1.URL + "/eureka," + peer2.URL + "/eureka," + peer3.URL + "/eureka"
+		eurekaConfig.Instance.LeaseRenewalIntervalInSeconds = 30
+
+		client := eureka.NewClient(eurekaConfig, config.ServerConfig{Hostname: "localhost"})
+
+		Expect(client.RegisterApp("PRICING-SERVICE", "pricing-1", 8080)).To(Succeed())
+		Expect(client.UpdateAppStatus("PRICING-SERVICE", "pricing-1", 8080, eureka.StatusUp)).To(Succeed())
+		Expect(client.DeleteApp("PRICING-SERVICE", "pricing-1")).To(Succeed())
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		Expect(requests).To(Equal(map[string]int{"peer1": 1, "peer2": 3}))
+
+	})
+
+})
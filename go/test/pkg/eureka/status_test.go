@@ -0,0 +1,110 @@
+package eureka
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/eureka"
+	"context"
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ = Describe("Eureka instance status", func() {
+
+	var mu sync.Mutex
+	var requests []string
+	var statuses []string
+	var server *httptest.Server
+	var eurekaConfig config.EurekaConfig
+
+	BeforeEach(func() {
+
+		requests, statuses = nil, nil
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			requests = append(requests, r.Method+" "+r.URL.RequestURI())
+
+			if r.Method == http.MethodPost {
+				var body eureka.AppRegistrationBody
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				statuses = append(statuses, body.Instance.Status)
+			}
+
+			w.WriteHeader(http.StatusOK)
+
+		}))
+
+		eurekaConfig = config.EurekaConfig{}
+		eurekaConfig.Client.ServiceURL.DefaultZone = server.URL + "/eureka"
+		eurekaConfig.Instance.IPAddress = "10.0.0.7"
+		eurekaConfig.Instance.LeaseRenewalIntervalInSeconds = 1
+
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Overrides the status with the Eureka API and removes the override", func() {
+
+		client := eureka.NewClient(eurekaConfig, config.ServerConfig{})
+
+		Expect(client.OverrideStatus(eureka.StatusOutOfService)).To(MatchError(eureka.ErrNotRegistered))
+
+		client.StartClient("PRICING-SERVICE", "pricing-1", 8080)
+		defer client.Stop("PRICING-SERVICE", "pricing-1", 8080)
+
+		Expect(client.OverrideStatus(eureka.StatusOutOfService)).To(Succeed())
+		Expect(client.InstanceStatus()).To(Equal(eureka.InstanceStatus{Status: eureka.StatusUp, OverriddenStatus: eureka.StatusOutOfService}))
+
+		Expect(client.OverrideStatus(eureka.StatusUp)).To(Succeed())
+		Expect(client.InstanceStatus().OverriddenStatus).To(Equal(eureka.StatusUnknown))
+
+		Expect(client.OverrideStatus(eureka.StatusDown)).To(MatchError(eureka.ErrInvalidStatus))
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		Expect(requests).To(ContainElements(
+			"PUT /eureka/apps/PRICING-SERVICE/pricing-1/status?value=OUT_OF_SERVICE",
+			"DELETE /eureka/apps/PRICING-SERVICE/pricing-1/status?value=UP",
+		))
+
+	})
+
+	It("Sends the status of the health when it changes", func() {
+
+		var status atomic.Value
+		status.Store(eureka.StatusUp)
+
+		client := eureka.NewClient(eurekaConfig, config.ServerConfig{})
+		client.SetStatusProvider(func(ctx context.Context) string {
+			return status.Load().(string)
+		})
+
+		client.StartClient("PRICING-SERVICE", "pricing-1", 8080)
+		defer client.Stop("PRICING-SERVICE", "pricing-1", 8080)
+
+		status.Store(eureka.StatusDown)
+
+		Eventually(func() string {
+			return client.InstanceStatus().Status
+		}, 5*time.Second, 50*time.Millisecond).Should(Equal(eureka.StatusDown))
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		Expect(statuses).To(Equal([]string{eureka.StatusStarting, eureka.StatusUp, eureka.StatusDown}))
+
+	})
+
+})
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/health"
+	"context"
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Health Suite")
+}
+
+// staticChecker always reports the same component
+type staticChecker struct {
+	name      string
+	component health.Component
+}
+
+func (sc *staticChecker) Name() string {
+	return sc.name
+}
+
+func (sc *staticChecker) Check(_ context.Context) health.Component {
+	return sc.component
+}
+
+var _ = Describe("Health", func() {
+
+	ctx := context.Background()
+
+	It("Is up when every component is up", func() {
+
+		h := health.NewHealth([]health.IChecker{
+			&staticChecker{"mongo", health.Up(nil)},
+			&staticChecker{"keycloak", health.Up(nil)},
+		}, config.ManagementConfig{})
+
+		component := h.Health(ctx)
+
+		Expect(component.Status).To(Equal(health.StatusUp))
+		Expect(component.Components).To(HaveKey("mongo"))
+		Expect(component.Components).To(HaveKey("keycloak"))
+
+	})
+
+	It("Is down when any component is down", func() {
+
+		h := health.NewHealth([]health.IChecker{
+			&staticChecker{"mongo", health.Down(errors.New("no reachable servers"), nil)},
+			&staticChecker{"keycloak", health.Up(nil)},
+		}, config.ManagementConfig{})
+
+		component := h.Readiness(ctx)
+
+		Expect(component.Status).To(Equal(health.StatusDown))
+		Expect(component.Components["mongo"].Details["error"]).To(Equal("no reachable servers"))
+
+	})
+
+	It("Keeps the liveness up regardless of the dependencies", func() {
+
+		h := health.NewHealth([]health.IChecker{
+			&staticChecker{"mongo", health.Down(errors.New("no reachable servers"), nil)},
+		}, config.ManagementConfig{})
+
+		Expect(h.Liveness(ctx).Status).To(Equal(health.StatusUp))
+
+	})
+
+	It("Leaves the excluded remote dependencies out of the readiness", func() {
+
+		h := health.NewHealth([]health.IChecker{
+			&staticChecker{"mongo", health.Up(nil)},
+			&staticChecker{"configServer", health.Down(errors.New("connection refused"), nil)},
+		}, config.ManagementConfig{Health: config.ManagementHealthConfig{
+			Readiness: config.ManagementHealthReadinessConfig{Exclude: []string{"configServer"}},
+		}})
+
+		Expect(h.Health(ctx).Status).To(Equal(health.StatusDown))
+		Expect(h.Readiness(ctx).Status).To(Equal(health.StatusUp))
+		Expect(h.Readiness(ctx).Components).NotTo(HaveKey("configServer"))
+
+	})
+
+	It("Hides the details of every component", func() {
+
+		component := health.WithoutDetails(health.Component{
+			Status: health.StatusDown,
+			Components: map[string]health.Component{
+				"configServer": health.Down(errors.New("connection refused"), map[string]interface{}{"url": "http://config:8888"}),
+			},
+		})
+
+		Expect(component.Status).To(Equal(health.StatusDown))
+		Expect(component.Components["configServer"].Status).To(Equal(health.StatusDown))
+		Expect(component.Components["configServer"].Details).To(BeNil())
+
+	})
+
+	It("Checks HTTP endpoints", func() {
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/down" {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+		defer server.Close()
+
+		Expect(health.NewHTTPChecker("up", server.URL+"/up").Check(ctx).Status).To(Equal(health.StatusUp))
+		Expect(health.NewHTTPChecker("down", server.URL+"/down").Check(ctx).Status).To(Equal(health.StatusDown))
+
+	})
+
+})
@@ -0,0 +1,181 @@
+package loadbalancer
+
+import (
+	"MicroserviceTemplate/pkg/loadbalancer"
+	"MicroserviceTemplate/pkg/registry"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Load Balancer Suite")
+}
+
+// registryOf returns an in-memory registry with the instances
+func registryOf(instances ...registry.Instance) registry.IServiceRegistry {
+
+	serviceRegistry := registry.NewInMemoryRegistry()
+	for _, instance := range instances {
+		_ = serviceRegistry.Register(instance)
+	}
+
+	return serviceRegistry
+
+}
+
+// instanceOf returns the instance served by the test server
+func instanceOf(id string, server *httptest.Server, zone string) registry.Instance {
+
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	portNumber, _ := strconv.Atoi(port)
+
+	return registry.Instance{
+		ID:       id,
+		AppName:  "PRICING-SERVICE",
+		Host:     host,
+		Port:     portNumber,
+		Metadata: map[string]string{"zone": zone},
+	}
+
+}
+
+// instanceServer returns a server that answers with its name and the path, or with the status when it is not 200
+func instanceServer(name string, status int, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(name + " " + r.URL.RequestURI()))
+	}))
+}
+
+// closeRecorder is a request body that records whether it was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (cr *closeRecorder) Close() error {
+	cr.closed = true
+	return nil
+}
+
+// body returns the body of the response
+func body(resp *http.Response) string {
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	content, _ := io.ReadAll(resp.Body)
+	return string(content)
+}
+
+var _ = Describe("Load balancer", func() {
+
+	var firstRequests, secondRequests int
+	var first, second *httptest.Server
+
+	BeforeEach(func() {
+		firstRequests, secondRequests = 0, 0
+		first = instanceServer("first", http.StatusOK, &firstRequests)
+		second = instanceServer("second", http.StatusOK, &secondRequests)
+	})
+
+	AfterEach(func() {
+		first.Close()
+		second.Close()
+	})
+
+	It("Resolves both URL forms and takes turns between the instances", func() {
+
+		serviceRegistry := registryOf(instanceOf("a", first, "east"), instanceOf("b", second, "west"))
+		client := loadbalancer.NewRoundTripper(serviceRegistry, loadbalancer.NewRoundRobinStrategy(), 2, time.Minute, http.DefaultTransport).Client()
+
+		resp, err := client.Get("lb://pricing-service/prices/1?currency=EUR")
+		Expect(err).To(BeNil())
+		Expect(body(resp)).To(Equal("first /prices/1?currency=EUR"))
+
+		resp, err = client.Get("http://lb/pricing-service/prices/2")
+		Expect(err).To(BeNil())
+		Expect(body(resp)).To(Equal("second /prices/2"))
+
+	})
+
+	It("Prefers the instances of its zone", func() {
+
+		serviceRegistry := registryOf(instanceOf("a", first, "east"), instanceOf("b", second, "west"))
+		client := loadbalancer.NewRoundTripper(serviceRegistry, loadbalancer.NewZonePreferenceStrategy("west"), 2, time.Minute, http.DefaultTransport).Client()
+
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get("lb://pricing-service/prices")
+			Expect(err).To(BeNil())
+			Expect(body(resp)).To(Equal("second /prices"))
+		}
+
+	})
+
+	It("Retries idempotent requests on another instance and evicts the failed one", func() {
+
+		first.Close()
+		first = instanceServer("first", http.StatusServiceUnavailable, &firstRequests)
+
+		serviceRegistry := registryOf(instanceOf("a", first, "east"), instanceOf("b", second, "west"))
+		client := loadbalancer.NewRoundTripper(serviceRegistry, loadbalancer.NewRoundRobinStrategy(), 2, time.Minute, http.DefaultTransport).Client()
+
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get("lb://pricing-service/prices")
+			Expect(err).To(BeNil())
+			Expect(body(resp)).To(Equal("second /prices"))
+		}
+
+		Expect(firstRequests).To(Equal(1))
+		Expect(secondRequests).To(Equal(3))
+
+	})
+
+	It("Does not retry requests that are not idempotent", func() {
+
+		first.Close()
+		first = instanceServer("first", http.StatusServiceUnavailable, &firstRequests)
+
+		serviceRegistry := registryOf(instanceOf("a", first, "east"), instanceOf("b", second, "west"))
+		client := loadbalancer.NewRoundTripper(serviceRegistry, loadbalancer.NewRoundRobinStrategy(), 2, time.Minute, http.DefaultTransport).Client()
+
+		resp, err := client.Post("lb://pricing-service/prices", "application/json", strings.NewReader(`{}`))
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		_ = resp.Body.Close()
+		Expect(secondRequests).To(Equal(0))
+
+	})
+
+	It("Fails when the application has no instances", func() {
+
+		client := loadbalancer.NewRoundTripper(registryOf(), loadbalancer.NewRandomStrategy(), 2, time.Minute, http.DefaultTransport).Client()
+
+		_, err := client.Get("lb://pricing-service/prices")
+		Expect(err).To(MatchError(ContainSubstring(loadbalancer.ErrNoInstances.Error())))
+
+	})
+
+	It("Closes the body of the request it cannot send", func() {
+
+		requestBody := &closeRecorder{Reader: strings.NewReader(`{"price": 10}`)}
+		req, err := http.NewRequest(http.MethodPost, "lb://pricing-service/prices", requestBody)
+		Expect(err).To(BeNil())
+
+		_, err = loadbalancer.NewRoundTripper(registryOf(), loadbalancer.NewRandomStrategy(), 2, time.Minute, http.DefaultTransport).RoundTrip(req)
+
+		Expect(err).To(MatchError(ContainSubstring(loadbalancer.ErrNoInstances.Error())))
+		Expect(requestBody.closed).To(BeTrue())
+
+	})
+
+})
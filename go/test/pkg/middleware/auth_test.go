@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/middleware"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// authorize runs a request through the authorization middleware against a Keycloak that knows no realm, so only the
+// excluded paths are let through
+func authorize(requestPath string, excludePaths ...string) int {
+
+	gin.SetMode(gin.TestMode)
+
+	keycloak := httptest.NewServer(http.NotFoundHandler())
+	defer keycloak.Close()
+
+	r := gin.New()
+	r.Use(middleware.IsAuthorizedJWT(config.KeycloakConfig{URL: keycloak.URL, Realm: "master"}, excludePaths...))
+	r.NoRoute(func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, requestPath, nil))
+
+	return recorder.Code
+
+}
+
+var _ = Describe("Authorization", func() {
+
+	AfterEach(func() {
+		middleware.SetExcludedPaths()
+	})
+
+	It("Lets the excluded paths through", func() {
+
+		Expect(authorize("/health", "/health/**")).To(Equal(http.StatusOK))
+		Expect(authorize("/health/liveness", "/health/**")).To(Equal(http.StatusOK))
+		Expect(authorize("/swagger/index.html", "/swagger/*any")).To(Equal(http.StatusOK))
+		Expect(authorize("/metrics", "/metrics")).To(Equal(http.StatusOK))
+
+	})
+
+	It("Matches the excluded paths by whole segments from the start of the path", func() {
+
+		Expect(authorize("/products/health/liveness", "/health/**")).To(Equal(http.StatusUnauthorized))
+		Expect(authorize("/products/metrics", "/metrics")).To(Equal(http.StatusUnauthorized))
+		Expect(authorize("/metricsz", "/metrics")).To(Equal(http.StatusUnauthorized))
+		Expect(authorize("/health/../products", "/health/**")).To(Equal(http.StatusUnauthorized))
+
+	})
+
+	It("Drops the configured paths that would exclude every route", func() {
+
+		middleware.SetExcludedPaths("", "  ", "/", "/**", "/public/**")
+
+		Expect(middleware.ExcludedPaths()).To(Equal([]string{"/public/**"}))
+		Expect(authorize("/products")).To(Equal(http.StatusUnauthorized))
+		Expect(authorize("/public/logo.png")).To(Equal(http.StatusOK))
+
+	})
+
+})
+
+var _ = Describe("Token verification", func() {
+
+	It("Fetches the discovery document of the realm once and reuses its verifier", func() {
+
+		discoveries := 0
+
+		var keycloak *httptest.Server
+		keycloak = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/realms/reused/.well-known/openid-configuration":
+				discoveries++
+				issuer := keycloak.URL + "/realms/reused"
+				_ = json.NewEncoder(w).Encode(map[string]string{
+					"issuer":                 issuer,
+					"authorization_endpoint": issuer + "/protocol/openid-connect/auth",
+					"token_endpoint":         issuer + "/protocol/openid-connect/token",
+					"jwks_uri":               issuer + "/protocol/openid-connect/certs",
+				})
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer keycloak.Close()
+
+		gin.SetMode(gin.TestMode)
+
+		r := gin.New()
+		r.Use(middleware.IsAuthorizedJWT(config.KeycloakConfig{URL: keycloak.URL, Realm: "reused"}))
+		r.NoRoute(func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		for i := 0; i < 3; i++ {
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/products", nil)
+			request.Header.Set("Authorization", "Bearer not-a-token")
+			r.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+
+		}
+
+		Expect(discoveries).To(Equal(1))
+
+	})
+
+})
+
+var _ = Describe("Roles", func() {
+
+	// requireRole runs a request with the realm roles of a verified token through the role middleware
+	requireRole := func(role string, claims *middleware.Claims) int {
+
+		gin.SetMode(gin.TestMode)
+
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			if claims != nil {
+				c.Set(middleware.ClaimsKey, claims)
+			}
+		})
+		r.Use(middleware.HasRole(role))
+		r.NoRoute(func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/actuator/refresh", nil))
+
+		return recorder.Code
+
+	}
+
+	claimsWith := func(roles ...string) *middleware.Claims {
+		var claims middleware.Claims
+		Expect(json.Unmarshal([]byte(`{"realm_access":{"roles":["`+strings.Join(roles, `","`)+`"]}}`), &claims)).To(Succeed())
+		return &claims
+	}
+
+	It("Lets through the tokens with the role", func() {
+		Expect(requireRole("ADMIN", claimsWith("USER", "ADMIN"))).To(Equal(http.StatusOK))
+	})
+
+	It("Forbids the tokens with other roles, the requests without a verified token and an empty role", func() {
+
+		Expect(requireRole("ADMIN", claimsWith("USER"))).To(Equal(http.StatusForbidden))
+		Expect(requireRole("ADMIN", nil)).To(Equal(http.StatusForbidden))
+		Expect(requireRole("", claimsWith("USER"))).To(Equal(http.StatusForbidden))
+
+	})
+
+})
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"MicroserviceTemplate/internal/product"
+	"MicroserviceTemplate/pkg/middleware"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Middleware Suite")
+}
+
+// serve runs a request through the problem details middleware with a handler that fails with the error
+func serve(err error) (*httptest.ResponseRecorder, map[string]interface{}) {
+
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(middleware.ProblemDetails())
+	r.GET("/products/:id", func(c *gin.Context) {
+		_ = c.Error(err)
+	})
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+
+	var body map[string]interface{}
+	Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+
+	return recorder, body
+
+}
+
+var _ = Describe("Problem details", func() {
+
+	It("Reports a product not found as 404", func() {
+
+		recorder, body := serve(product.NewError(product.KindNotFound, "product not found", mongo.ErrNoDocuments))
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("application/problem+json"))
+		Expect(body["status"]).To(BeEquivalentTo(http.StatusNotFound))
+		Expect(body["code"]).To(Equal("not_found"))
+		Expect(body["instance"]).To(Equal("/products/1"))
+
+	})
+
+	It("Reports an unavailable database as 503", func() {
+
+		recorder, body := serve(product.ErrUnavailable)
+
+		Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(body["code"]).To(Equal("unavailable"))
+
+	})
+
+	It("Finds the product error through wrapping", func() {
+
+		recorder, body := serve(fmt.Errorf("%w: test failed", product.ErrPatchTestFailed))
+
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+		Expect(body["detail"]).To(Equal("patch test operation failed: test failed"))
+
+	})
+
+	It("Reports unknown errors as 500", func() {
+
+		recorder, body := serve(errors.New("boom"))
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(body["title"]).To(Equal(http.StatusText(http.StatusInternalServerError)))
+
+	})
+
+})
@@ -0,0 +1,69 @@
+package pagination
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/internal/domain"
+	"MicroserviceTemplate/pkg/pagination"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"strings"
+	"testing"
+)
+
+func TestTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Pagination Token Suite")
+}
+
+var _ = Describe("Continuation token", func() {
+
+	codec := pagination.NewTokenCodec(config.PaginationConfig{TokenSecret: "secret"})
+	cursor := domain.ProductCursor{SortField: "price", SortOrder: domain.SortDescending, SortValue: 12.5, LastID: "abc"}
+
+	It("Round trips the cursor", func() {
+
+		token, err := codec.Encode(cursor)
+		Expect(err).NotTo(HaveOccurred())
+
+		var decoded domain.ProductCursor
+		err = codec.Decode(token, &decoded)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decoded).To(Equal(cursor))
+
+	})
+
+	It("Rejects a tampered token", func() {
+
+		token, err := codec.Encode(cursor)
+		Expect(err).NotTo(HaveOccurred())
+
+		data, signature, _ := strings.Cut(token, ".")
+		tampered := "e" + data[1:] + "." + signature
+		if tampered == token {
+			tampered = "f" + data[1:] + "." + signature
+		}
+
+		var decoded domain.ProductCursor
+		Expect(codec.Decode(tampered, &decoded)).To(MatchError(pagination.ErrInvalidToken))
+
+	})
+
+	It("Rejects a token signed by another key", func() {
+
+		token, err := pagination.NewTokenCodec(config.PaginationConfig{TokenSecret: "another-secret"}).Encode(cursor)
+		Expect(err).NotTo(HaveOccurred())
+
+		var decoded domain.ProductCursor
+		Expect(codec.Decode(token, &decoded)).To(MatchError(pagination.ErrInvalidToken))
+
+	})
+
+	It("Rejects garbage", func() {
+
+		var decoded domain.ProductCursor
+		Expect(codec.Decode("not-a-token", &decoded)).To(MatchError(pagination.ErrInvalidToken))
+
+	})
+
+})
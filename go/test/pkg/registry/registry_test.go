@@ -0,0 +1,254 @@
+package registry
+
+import (
+	"MicroserviceTemplate/config"
+	"MicroserviceTemplate/pkg/registry"
+	"context"
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Service Registry Suite")
+}
+
+var _ = Describe("In-memory registry", func() {
+
+	It("Registers, renews and deregisters the instances", func() {
+
+		serviceRegistry := registry.NewInMemoryRegistry()
+		instance := registry.Instance{ID: "pricing-1", AppName: "pricing-service", Host: "10.0.0.7", Port: 8080}
+
+		Expect(serviceRegistry.Heartbeat(instance)).To(MatchError(registry.ErrNotRegistered))
+
+		Expect(serviceRegistry.Register(instance)).To(Succeed())
+		Expect(serviceRegistry.Heartbeat(instance)).To(Succeed())
+
+		instances, err := serviceRegistry.GetInstances("PRICING-SERVICE")
+		Expect(err).To(BeNil())
+		Expect(instances).To(HaveLen(1))
+		Expect(instances[0].Status).To(Equal(registry.StatusUp))
+
+		Expect(serviceRegistry.Deregister(instance)).To(Succeed())
+
+		instances, err = serviceRegistry.GetInstances("pricing-service")
+		Expect(err).To(BeNil())
+		Expect(instances).To(BeEmpty())
+
+	})
+
+	It("Registers the local instance on a reachable host", func() {
+
+		server := config.ServerConfig{Hostname: "pricing.local"}
+		server.SSL.Enabled = true
+
+		Expect(registry.NewLocalInstance("pricing-1", "pricing-service", 8443, server)).To(Equal(registry.Instance{
+			ID:      "pricing-1",
+			AppName: "pricing-service",
+			Host:    "pricing.local",
+			Port:    8443,
+			Secure:  true,
+		}))
+		Expect(registry.NewLocalInstance("pricing-1", "pricing-service", 8080, config.ServerConfig{}).Host).NotTo(BeEmpty())
+
+	})
+
+})
+
+var _ = Describe("Consul registry", func() {
+
+	var mu sync.Mutex
+	var registered map[string]interface{}
+	var requests []string
+	var server *httptest.Server
+
+	BeforeEach(func() {
+
+		registered, requests = nil, nil
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			requests = append(requests, r.Method+" "+r.URL.RequestURI())
+
+			switch r.URL.Path {
+			case "/v1/agent/service/register":
+				_ = json.NewDecoder(r.Body).Decode(&registered)
+			case "/v1/agent/check/pass/service:unknown":
+				w.WriteHeader(http.StatusNotFound)
+			case "/v1/health/service/pricing-service":
+				_, _ = w.Write([]byte(`[
+					{"Node": {"Address": "10.0.0.1"}, "Service": {"ID": "pricing-1", "Service": "pricing-service", "Address": "", "Port": 8080}},
+					{"Node": {"Address": "10.0.0.2"}, "Service": {"ID": "pricing-2", "Service": "pricing-service", "Address": "10.0.0.9", "Port": 8443, "Meta": {"secure": "true"}}}
+				]`))
+			}
+
+		}))
+
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	consulRegistry := func() *registry.ConsulRegistry {
+
+		var consul config.ConsulConfig
+		consul.URL = server.URL
+		consul.Token = "secret"
+		consul.Discovery.IPAddress = "10.0.0.7"
+		consul.Discovery.PreferIPAddress = true
+		consul.Discovery.HealthCheckTTL = time.Minute
+		consul.Discovery.DeregisterCriticalServiceAfter = 5 * time.Minute
+		consul.Discovery.Metadata = map[string]string{"zone": "zone-a"}
+
+		return registry.NewConsulRegistry(consul, config.ServerConfig{})
+
+	}
+
+	It("Registers the instance with a TTL check and deregisters it", func() {
+
+		serviceRegistry := consulRegistry()
+		instance := registry.Instance{ID: "pricing-1", AppName: "PRICING-SERVICE", Port: 8080}
+
+		Expect(serviceRegistry.Register(instance)).To(Succeed())
+		Expect(serviceRegistry.Heartbeat(instance)).To(Succeed())
+		Expect(serviceRegistry.Deregister(instance)).To(Succeed())
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		Expect(registered).To(HaveKeyWithValue("Name", "pricing-service"))
+		Expect(registered).To(HaveKeyWithValue("Address", "10.0.0.7"))
+		Expect(registered).To(HaveKeyWithValue("Meta", map[string]interface{}{"zone": "zone-a", "secure": "false"}))
+		Expect(registered).To(HaveKeyWithValue("Check", map[string]interface{}{
+			"CheckID":                        "service:pricing-1",
+			"TTL":                            "1m0s",
+			"Status":                         "passing",
+			"DeregisterCriticalServiceAfter": "5m0s",
+		}))
+
+		Expect(requests).To(Equal([]string{
+			"PUT /v1/agent/service/register",
+			"PUT /v1/agent/check/pass/service:pricing-1",
+			"PUT /v1/agent/service/deregister/pricing-1",
+		}))
+
+	})
+
+	It("Makes the TTL check warn while the status provider does not report UP", func() {
+
+		status := "DOWN"
+
+		serviceRegistry := consulRegistry()
+		serviceRegistry.SetStatusProvider(func(ctx context.Context) string {
+			return status
+		})
+		instance := registry.Instance{ID: "pricing-1", AppName: "PRICING-SERVICE", Port: 8080}
+
+		Expect(serviceRegistry.Register(instance)).To(Succeed())
+		Expect(serviceRegistry.Heartbeat(instance)).To(Succeed())
+		status = registry.StatusUp
+		Expect(serviceRegistry.Heartbeat(instance)).To(Succeed())
+		Expect(serviceRegistry.Deregister(instance)).To(Succeed())
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		Expect(registered).To(HaveKeyWithValue("Check", HaveKeyWithValue("Status", "warning")))
+		Expect(requests).To(Equal([]string{
+			"PUT /v1/agent/service/register",
+			"PUT /v1/agent/check/warn/service:pricing-1?note=the+instance+is+DOWN",
+			"PUT /v1/agent/check/pass/service:pricing-1",
+			"PUT /v1/agent/service/deregister/pricing-1",
+		}))
+
+	})
+
+	It("Waits for the heartbeat in flight before deregistering, without registering the instance again", func() {
+
+		inFlight, release := make(chan struct{}), make(chan struct{})
+		var agentMu sync.Mutex
+		var agentRequests []string
+
+		// The agent holds the first heartbeat and answers it as if the instance were already deregistered
+		agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			agentMu.Lock()
+			agentRequests = append(agentRequests, r.Method+" "+r.URL.Path)
+			heartbeats := len(agentRequests)
+			agentMu.Unlock()
+
+			if r.URL.Path == "/v1/agent/check/pass/service:pricing-1" && heartbeats == 2 {
+				close(inFlight)
+				select {
+				case <-release:
+				case <-time.After(time.Second):
+				}
+				w.WriteHeader(http.StatusNotFound)
+			}
+
+		}))
+		defer agent.Close()
+
+		var consul config.ConsulConfig
+		consul.URL = agent.URL
+		consul.Discovery.IPAddress = "10.0.0.7"
+		consul.Discovery.HealthCheckTTL = 20 * time.Millisecond
+
+		serviceRegistry := registry.NewConsulRegistry(consul, config.ServerConfig{})
+		instance := registry.Instance{ID: "pricing-1", AppName: "PRICING-SERVICE", Port: 8080}
+
+		Expect(serviceRegistry.Register(instance)).To(Succeed())
+		Eventually(inFlight).Should(BeClosed())
+
+		deregistered := make(chan error, 1)
+		go func() {
+			deregistered <- serviceRegistry.Deregister(instance)
+		}()
+
+		Consistently(deregistered, 50*time.Millisecond).ShouldNot(Receive())
+		close(release)
+		Eventually(deregistered).Should(Receive(BeNil()))
+
+		agentMu.Lock()
+		defer agentMu.Unlock()
+
+		Expect(agentRequests).To(Equal([]string{
+			"PUT /v1/agent/service/register",
+			"PUT /v1/agent/check/pass/service:pricing-1",
+			"PUT /v1/agent/service/deregister/pricing-1",
+		}))
+
+	})
+
+	It("Reports the instances the agent no longer knows", func() {
+
+		err := consulRegistry().Heartbeat(registry.Instance{ID: "unknown", AppName: "pricing-service"})
+
+		Expect(err).To(MatchError(registry.ErrNotRegistered))
+
+	})
+
+	It("Finds the passing instances, on the address of their node when they have none", func() {
+
+		instances, err := consulRegistry().GetInstances("PRICING-SERVICE")
+
+		Expect(err).To(BeNil())
+		Expect(instances).To(Equal([]registry.Instance{
+			{ID: "pricing-1", AppName: "pricing-service", Host: "10.0.0.1", Port: 8080, Status: registry.StatusUp},
+			{ID: "pricing-2", AppName: "pricing-service", Host: "10.0.0.9", Port: 8443, Secure: true, Status: registry.StatusUp, Metadata: map[string]string{"secure": "true"}},
+		}))
+
+	})
+
+})